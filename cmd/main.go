@@ -1,8 +1,16 @@
 package main
 
-import "github.com/thereayou/discord-lite/cmd/server"
+import (
+	"os"
+
+	"github.com/thereayou/discord-lite/cmd/server"
+)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	srv := server.NewServer()
 	srv.Run()
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/thereayou/discord-lite/cmd/server"
+	"github.com/thereayou/discord-lite/internal/database"
+)
+
+const migrateUsage = `usage: migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [N]    roll back the last N migrations (default 1)
+  status      list migrations and whether they are applied`
+
+// runMigrate выполняет подкоманду migrate и возвращает код выхода
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	steps := 1
+	switch args[0] {
+	case "up", "status":
+		if len(args) > 1 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+	case "down":
+		if len(args) > 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				fmt.Fprintln(os.Stderr, "migrate down: N must be a positive number")
+				return 2
+			}
+			steps = n
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	server.LoadEnv()
+
+	db, err := database.OpenPostgres()
+	if err != nil {
+		log.Printf("Postgres connect failed: %v", err)
+		return 1
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Printf("Load migrations failed: %v", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up()
+		if err != nil {
+			log.Printf("Migrate up failed after %d migration(s): %v", n, err)
+			return 1
+		}
+		fmt.Printf("Applied %d migration(s)\n", n)
+
+	case "down":
+		n, err := migrator.Down(steps)
+		if err != nil {
+			log.Printf("Migrate down failed after %d migration(s): %v", n, err)
+			return 1
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", n)
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Printf("Migrate status failed: %v", err)
+			return 1
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", st.Version, st.Name, applied)
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Как часто запускается очистка удаленных сообщений
+const retentionInterval = time.Hour
+
+// runMessageRetention периодически стирает содержимое сообщений, удаленных дольше retention назад
+func (s *Server) runMessageRetention(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		s.purgeDeletedMessages(ctx, retention)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) purgeDeletedMessages(ctx context.Context, retention time.Duration) {
+	purged, attachments, err := s.DB.PurgeDeletedMessages(time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Message retention error: %v", err)
+	}
+
+	// Файлы удаляются после коммита; потерянный файл безопаснее висящей ссылки
+	for _, attachment := range attachments {
+		for _, url := range []string{attachment.FileURL, attachment.ThumbnailURL} {
+			key, ok := s.Storage.KeyFromURL(url)
+			if !ok {
+				continue
+			}
+			if err := s.Storage.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete stored file %s: %v", key, err)
+			}
+		}
+	}
+
+	if purged > 0 {
+		log.Printf("Message retention: purged %d deleted messages", purged)
+	}
+}
@@ -3,7 +3,6 @@ package server
 import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/thereayou/discord-lite/internal/handlers"
 	"github.com/thereayou/discord-lite/internal/middleware"
 )
 
@@ -13,6 +12,7 @@ func APIEndpoints(r *gin.Engine, s *Server) {
 	config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:5173"} // для разработки
 	config.AllowCredentials = true
 	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.ExposeHeaders = []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
 	r.Use(cors.New(config))
 
 	// Health check
@@ -23,20 +23,41 @@ func APIEndpoints(r *gin.Engine, s *Server) {
 	// Auth endpoints
 	auth := r.Group("/auth")
 	{
-		auth.POST("/register", s.AuthH.Register)
-		auth.POST("/login", s.AuthH.Login)
-		auth.POST("/logout", middleware.AuthMiddleware(s.JWTManager, s.Redis), s.AuthH.Logout)
+		auth.POST("/register", middleware.RateLimit(s.Limiter, middleware.ByIP("register", middleware.RegisterLimit)), s.AuthH.Register)
+		auth.POST("/login", middleware.RateLimit(s.Limiter, middleware.ByIP("login", middleware.LoginLimit)), s.AuthH.Login)
+		auth.POST("/refresh", middleware.RateLimit(s.Limiter, middleware.ByIP("refresh", middleware.RefreshLimit)), s.AuthH.Refresh)
+		auth.POST("/logout", middleware.AuthMiddleware(s.JWTManager, s.Sessions, s.Redis), s.AuthH.Logout)
 	}
 
 	// API endpoints с аутентификацией
 	api := r.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware(s.JWTManager, s.Redis))
+	api.Use(middleware.AuthMiddleware(s.JWTManager, s.Sessions, s.Redis))
 	{
 		// User endpoints
 		api.GET("/users/me", s.UserH.GetMe)
 		api.PUT("/users/me", s.UserH.UpdateMe)
+		api.GET("/users/me/sessions", s.AuthH.ListSessions)
+		api.DELETE("/users/me/sessions", s.AuthH.RevokeOtherSessions)
+		api.DELETE("/users/me/sessions/:id", s.AuthH.RevokeSession)
 		api.GET("/users/:id", s.UserH.GetUser)
 		api.GET("/users/search", s.UserH.SearchUsers)
+		api.GET("/users/me/blocks", s.BlockH.GetBlocks)
+		api.POST("/users/:id/block", s.BlockH.BlockUser)
+		api.DELETE("/users/:id/block", s.BlockH.UnblockUser)
+		api.POST("/users/me/push-tokens", s.PushH.RegisterToken)
+		api.DELETE("/users/me/push-tokens", s.PushH.UnregisterToken)
+		api.GET("/push/vapid-public-key", s.PushH.GetVAPIDPublicKey)
+		api.PUT("/users/me/presence", s.PresenceH.UpdateMyPresence)
+		api.POST("/presence/query", s.PresenceH.QueryPresence)
+
+		// Friend endpoints
+		api.GET("/friends", s.FriendH.GetFriends)
+		api.DELETE("/friends/:user_id", s.FriendH.RemoveFriend)
+		api.GET("/friends/requests", s.FriendH.GetFriendRequests)
+		api.POST("/friends/requests", middleware.RateLimit(s.Limiter, middleware.ByUser("friend_request", middleware.FriendRequestLimit)), s.FriendH.SendFriendRequest)
+		api.POST("/friends/requests/:user_id/accept", s.FriendH.AcceptFriendRequest)
+		api.POST("/friends/requests/:user_id/decline", s.FriendH.DeclineFriendRequest)
+		api.DELETE("/friends/requests/:user_id", s.FriendH.CancelFriendRequest)
 
 		// Room endpoints
 		api.POST("/rooms", s.RoomH.CreateRoom)
@@ -47,20 +68,46 @@ func APIEndpoints(r *gin.Engine, s *Server) {
 		api.POST("/rooms/:id/join", s.RoomH.JoinRoom)
 		api.POST("/rooms/:id/leave", s.RoomH.LeaveRoom)
 		api.GET("/rooms/:id/members", s.RoomH.GetRoomMembers)
+		api.PUT("/rooms/:id/members/:user_id/role", s.RoomH.UpdateMemberRole)
+		api.POST("/rooms/:id/members/:user_id/kick", s.RoomH.KickMember)
+		api.POST("/rooms/:id/members/:user_id/ban", s.RoomH.BanMember)
+		api.GET("/rooms/:id/bans", s.RoomH.GetRoomBans)
+		api.DELETE("/rooms/:id/bans/:user_id", s.RoomH.UnbanMember)
+		api.GET("/rooms/:id/audit-log", s.RoomH.GetMessageAuditLog)
+		api.POST("/rooms/:id/read", s.ReadReceiptH.MarkRead)
+		api.GET("/rooms/:id/read-receipts", s.ReadReceiptH.GetReadReceipts)
+		api.PUT("/rooms/:id/notifications", s.PushH.UpdateRoomNotifications)
+		api.POST("/rooms/:id/invites", s.RoomH.CreateInvite)
+		api.GET("/rooms/:id/invites", s.RoomH.GetInvites)
+		api.DELETE("/rooms/:id/invites/:code", s.RoomH.RevokeInvite)
+
+		// Invite endpoints
+		api.GET("/invites/:code", s.RoomH.GetInvite)
+		api.POST("/invites/:code/accept", middleware.RateLimit(s.Limiter, middleware.ByUser("invite_accept", middleware.InviteAcceptLimit)), s.RoomH.AcceptInvite)
 
 		// Direct room
 		api.POST("/rooms/direct", s.RoomH.CreateDirectRoom)
 
 		// Message endpoints
-		api.GET("/rooms/:id/messages", s.MessageH.GetRoomMessages)
-		api.POST("/rooms/:id/messages", s.MessageH.SendMessage)
-		api.PUT("/messages/:id", s.MessageH.UpdateMessage)
-		api.DELETE("/messages/:id", s.MessageH.DeleteMessage)
+		api.GET("/rooms/:id/messages", s.HTTPMessageH.GetRoomMessages)
+		api.POST("/rooms/:id/messages", middleware.RateLimit(s.Limiter, middleware.MessageRequestBudgets), s.HTTPMessageH.SendMessage)
+		api.POST("/rooms/:id/attachments", middleware.RateLimit(s.Limiter, middleware.MessageRequestBudgets), s.AttachmentH.UploadAttachments)
+		api.GET("/files/*key", s.AttachmentH.ServeAttachment)
+		api.GET("/search/messages", s.HTTPMessageH.SearchMessages)
+		api.GET("/messages/:id/thread", s.HTTPMessageH.GetThread)
+		api.PUT("/messages/:id", s.HTTPMessageH.UpdateMessage)
+		api.GET("/messages/:id/revisions", s.HTTPMessageH.GetMessageRevisions)
+		api.DELETE("/messages/:id", s.HTTPMessageH.DeleteMessage)
+		api.POST("/messages/:id/restore", s.HTTPMessageH.RestoreMessage)
+
+		// Reaction endpoints
+		api.POST("/messages/:id/reactions", s.ReactionH.AddReaction)
+		api.DELETE("/messages/:id/reactions/:emoji", s.ReactionH.RemoveReaction)
 	}
 
 	// WebSocket endpoint с аутентификацией
 	ws := r.Group("/ws")
-	ws.Use(middleware.WSAuthMiddleware(s.JWTManager, s.Redis))
+	ws.Use(middleware.WSAuthMiddleware(s.JWTManager, s.Sessions, s.Redis))
 	{
 		ws.GET("", s.WSHandler.HandleWebSocket)
 	}
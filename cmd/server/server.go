@@ -2,15 +2,27 @@ package server
 
 import (
 	"context"
+	"errors"
+	"expvar"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
 	"github.com/thereayou/discord-lite/internal/database"
 	"github.com/thereayou/discord-lite/internal/handlers"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/push"
+	"github.com/thereayou/discord-lite/internal/services"
 	"github.com/thereayou/discord-lite/internal/websocket"
 	"github.com/thereayou/discord-lite/pkg/auth"
+	"github.com/thereayou/discord-lite/pkg/ratelimit"
+	"github.com/thereayou/discord-lite/pkg/storage"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -19,22 +31,45 @@ type Server struct {
 	DB         *database.Database
 	Redis      *redis.Client
 	JWTManager *auth.JWTManager
+	Sessions   *auth.SessionManager
+	Limiter    *ratelimit.Limiter
 	Hub        *websocket.Hub
+	Storage    *storage.LocalStorage
 	// Handlers
 	AuthH        *handlers.AuthHandler
 	UserH        *handlers.UserHandler
 	RoomH        *handlers.RoomHandler
 	HTTPMessageH *handlers.HTTPMessageHandler
+	ReactionH    *handlers.ReactionHandler
+	AttachmentH  *handlers.AttachmentHandler
+	ReadReceiptH *handlers.ReadReceiptHandler
+	BlockH       *handlers.BlockHandler
+	PushH        *handlers.PushHandler
+	PresenceH    *handlers.PresenceHandler
+	FriendH      *handlers.FriendHandler
 	WSHandler    *handlers.WebSocketHandler
+
+	// Остановка фоновых задач
+	stopJobs context.CancelFunc
+	jobs     sync.WaitGroup
+
+	httpServer      *http.Server
+	debugServer     *http.Server
+	shutdownTimeout time.Duration
 }
 
-func NewServer() *Server {
-	// Load environment variables
+// LoadEnv загружает переменные окружения из .env.local или .env
+func LoadEnv() {
 	if err := godotenv.Load(".env.local"); err != nil {
 		if err := godotenv.Load(); err != nil {
 			log.Println(".env not found, using environment variables")
 		}
 	}
+}
+
+func NewServer() *Server {
+	// Load environment variables
+	LoadEnv()
 
 	// Database connection
 	dbConn := &database.Database{}
@@ -69,23 +104,110 @@ func NewServer() *Server {
 		log.Println("WARNING: Using default JWT secret. Change this in production!")
 	}
 
-	jwtMgr := auth.NewJWTManager(jwtSecret, 24*time.Hour)
+	accessTTL := 15 * time.Minute
+	if v := os.Getenv("ACCESS_TOKEN_TTL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			accessTTL = parsed
+		}
+	}
+
+	refreshTTL := 30 * 24 * time.Hour
+	if v := os.Getenv("REFRESH_TOKEN_TTL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			refreshTTL = parsed
+		}
+	}
+
+	jwtMgr := auth.NewJWTManager(jwtSecret, accessTTL)
+	sessions := auth.NewSessionManager(rdb, refreshTTL)
 
-	// WebSocket Hub
+	// Ограничение частоты запросов; 5 неудачных входов за 15 минут блокируют email на 15 минут
+	limiter := ratelimit.NewLimiter(rdb)
+	loginLockout := ratelimit.NewLockout(rdb, 5, 15*time.Minute, 15*time.Minute)
+
+	// WebSocket Hub, события рассылаются между инстансами через Redis
 	hub := websocket.NewHub()
+	hub.SetCluster(websocket.NewCluster(rdb))
+	hub.SetMembershipChecker(dbConn)
+	hub.SetContactSource(dbConn)
+	hub.SetBlockSource(dbConn)
 	go hub.Run()
 
+	// Хранилище вложений
+	uploadDir := os.Getenv("UPLOAD_DIR")
+	if uploadDir == "" {
+		uploadDir = "./uploads"
+	}
+
+	store, err := storage.NewLocalStorage(uploadDir, "/api/v1/files")
+	if err != nil {
+		log.Fatalf("Upload storage init failed: %v", err)
+	}
+
+	maxUploadSize := int64(10 << 20)
+	if v := os.Getenv("UPLOAD_MAX_SIZE"); v != "" {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil && parsed > 0 {
+			maxUploadSize = parsed
+		}
+	}
+
+	// Через сколько стирается содержимое удаленных сообщений, 0 отключает очистку
+	messageRetention := 30 * 24 * time.Hour
+	if v := os.Getenv("MESSAGE_RETENTION"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed >= 0 {
+			messageRetention = parsed
+		}
+	}
+
+	// Сколько ждать завершения запросов и закрытия соединений при остановке
+	shutdownTimeout := 15 * time.Second
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			shutdownTimeout = parsed
+		}
+	}
+
+	// Push-уведомления: Web Push включается парой VAPID ключей,
+	// PUSH_PROVIDER=fake только пишет уведомления в лог
+	pushWorker := push.NewWorker(dbConn, hub)
+	vapidPublicKey := ""
+	if pub, priv := os.Getenv("VAPID_PUBLIC_KEY"), os.Getenv("VAPID_PRIVATE_KEY"); pub != "" && priv != "" {
+		webPush, err := push.NewWebPushProvider(pub, priv, os.Getenv("VAPID_SUBJECT"))
+		if err != nil {
+			log.Fatalf("Web Push init failed: %v", err)
+		}
+		pushWorker.SetProvider(models.PlatformWeb, webPush)
+		vapidPublicKey = webPush.PublicKey()
+	}
+	if os.Getenv("PUSH_PROVIDER") == "fake" {
+		fake := push.NewFakeProvider()
+		for _, platform := range []string{models.PlatformWeb, models.PlatformIOS, models.PlatformAndroid} {
+			pushWorker.SetProvider(platform, fake)
+		}
+	}
+
 	// Initialize handlers
-	authH := handlers.NewAuthHandler(dbConn, jwtMgr, rdb)
+	authH := handlers.NewAuthHandler(dbConn, jwtMgr, sessions, rdb, hub, loginLockout)
 	userH := handlers.NewUserHandler(dbConn)
 	roomH := handlers.NewRoomHandler(dbConn, hub)
 
+	// Общий сервис сообщений для REST и WebSocket
+	messageService := services.NewMessageService(dbConn, hub)
+	messageService.SetNotifier(pushWorker)
+
 	// Message handler нужен для WebSocket handler
-	msgHandler := handlers.NewMessageHandler(dbConn, hub)
-	wsHandler := handlers.NewWebSocketHandler(hub, msgHandler)
+	msgHandler := handlers.NewMessageHandler(dbConn, hub, messageService, limiter)
+	wsHandler := handlers.NewWebSocketHandler(dbConn, hub, msgHandler)
 
 	// HTTP message handler для REST API
-	messageH := handlers.NewHTTPMessageHandler(dbConn)
+	messageH := handlers.NewHTTPMessageHandler(dbConn, messageService)
+	reactionH := handlers.NewReactionHandler(dbConn, hub)
+	attachmentH := handlers.NewAttachmentHandler(dbConn, messageService, store, maxUploadSize)
+	readReceiptH := handlers.NewReadReceiptHandler(dbConn, hub)
+	blockH := handlers.NewBlockHandler(dbConn, hub)
+	pushH := handlers.NewPushHandler(dbConn, vapidPublicKey)
+	presenceH := handlers.NewPresenceHandler(hub)
+	friendH := handlers.NewFriendHandler(dbConn, hub)
 
 	// Setup router
 	router := gin.Default()
@@ -98,39 +220,145 @@ func NewServer() *Server {
 		DB:           dbConn,
 		Redis:        rdb,
 		JWTManager:   jwtMgr,
+		Sessions:     sessions,
+		Limiter:      limiter,
 		Hub:          hub,
+		Storage:      store,
 		AuthH:        authH,
 		UserH:        userH,
 		RoomH:        roomH,
 		HTTPMessageH: messageH,
+		ReactionH:    reactionH,
+		AttachmentH:  attachmentH,
+		ReadReceiptH: readReceiptH,
+		BlockH:       blockH,
+		PushH:        pushH,
+		PresenceH:    presenceH,
+		FriendH:      friendH,
 		WSHandler:    wsHandler,
+
+		shutdownTimeout: shutdownTimeout,
 	}
 
 	// Setup routes
 	APIEndpoints(router, server)
 
+	// Фоновые задачи
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	server.stopJobs = stopJobs
+	if messageRetention > 0 {
+		server.jobs.Add(1)
+		go func() {
+			defer server.jobs.Done()
+			server.runMessageRetention(jobsCtx, messageRetention)
+		}()
+	}
+
+	server.jobs.Add(1)
+	go func() {
+		defer server.jobs.Done()
+		pushWorker.Run(jobsCtx)
+	}()
+
 	return server
 }
 
+// Run обслуживает HTTP до SIGINT/SIGTERM и затем корректно останавливает сервер
 func (s *Server) Run() {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	log.Printf("Server starting on port %s", port)
-	log.Printf("WebSocket hub is running")
+	s.httpServer = &http.Server{
+		Addr:    ":" + port,
+		Handler: s.Router,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on port %s", port)
+		log.Printf("WebSocket hub is running")
+
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	// Счетчики процесса и доставки WebSocket (expvar) только на внутреннем адресе
+	if debugAddr := os.Getenv("DEBUG_ADDR"); debugAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		s.debugServer = &http.Server{Addr: debugAddr, Handler: mux}
+
+		go func() {
+			log.Printf("Debug endpoints on %s", debugAddr)
+			if err := s.debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("Debug server error: %v", err)
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
 
-	if err := s.Router.Run(":" + port); err != nil {
-		log.Fatalf("Server run error: %v", err)
+	select {
+	case sig := <-quit:
+		log.Printf("Received %s", sig)
+	case err := <-serveErr:
+		log.Printf("Server run error: %v", err)
 	}
+
+	s.Shutdown()
 }
 
+// Shutdown останавливает сервер за shutdownTimeout: перестает принимать запросы и дожидается
+// текущих, закрывает WebSocket клиентов, дописав их очереди, останавливает фоновые задачи
+// и закрывает пулы Redis и Postgres
 func (s *Server) Shutdown() {
 	log.Println("Shutting down server...")
-	s.Hub.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	// WebSocket соединения http.Server не отслеживает, ими занимается hub
+	if s.httpServer != nil {
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			log.Printf("HTTP shutdown error: %v", err)
+		}
+	}
+	if s.debugServer != nil {
+		s.debugServer.Shutdown(ctx)
+	}
+
+	if err := s.Hub.Shutdown(ctx); err != nil {
+		log.Printf("WebSocket hub shutdown error: %v", err)
+	}
+
+	s.stopJobs()
+	jobsDone := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		log.Printf("Background jobs did not stop in time")
+	}
+
 	// Закрываем соединения
 	if s.Redis != nil {
-		s.Redis.Close()
+		if err := s.Redis.Close(); err != nil {
+			log.Printf("Redis close error: %v", err)
+		}
 	}
+	if s.DB != nil {
+		if err := s.DB.Close(); err != nil {
+			log.Printf("Postgres close error: %v", err)
+		}
+	}
+
+	log.Println("Server stopped")
 }
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BlockUser блокирует пользователя, повторная блокировка игнорируется.
+// Блокировка разрывает дружбу и удаляет заявки между пользователями.
+func (d *Database) BlockUser(block *models.UserBlock) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Omit("Blocker", "Blocked").
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(block).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where(pairCondition, block.BlockerID, block.BlockedID, block.BlockedID, block.BlockerID).
+			Delete(&models.Friendship{}).Error
+	})
+}
+
+func (d *Database) UnblockUser(blockerID, blockedID uuid.UUID) error {
+	return d.db.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Delete(&models.UserBlock{}).Error
+}
+
+// GetBlocks возвращает блокировки, созданные пользователем, с данными заблокированных
+func (d *Database) GetBlocks(blockerID uuid.UUID) ([]models.UserBlock, error) {
+	var blocks []models.UserBlock
+	err := d.db.Where("blocker_id = ?", blockerID).
+		Preload("Blocked").
+		Order("created_at DESC").
+		Find(&blocks).Error
+	return blocks, err
+}
+
+// GetBlockedIDs возвращает пользователей, заблокированных userID
+func (d *Database) GetBlockedIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := d.db.Model(&models.UserBlock{}).
+		Where("blocker_id = ?", userID).
+		Pluck("blocked_id", &ids).Error
+	return ids, err
+}
+
+// GetBlockerIDs возвращает пользователей, заблокировавших userID
+func (d *Database) GetBlockerIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := d.db.Model(&models.UserBlock{}).
+		Where("blocked_id = ?", userID).
+		Pluck("blocker_id", &ids).Error
+	return ids, err
+}
+
+// IsBlockedEither проверяет, заблокировал ли кто-то из пары другого
+func (d *Database) IsBlockedEither(user1ID, user2ID uuid.UUID) (bool, error) {
+	var count int64
+	err := d.db.Model(&models.UserBlock{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", user1ID, user2ID, user2ID, user1ID).
+		Count(&count).Error
+	return count > 0, err
+}
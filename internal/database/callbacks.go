@@ -0,0 +1,47 @@
+package database
+
+import (
+	"reflect"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var uuidType = reflect.TypeOf(uuid.UUID{})
+
+func registerCallbacks(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("discord:assign_uuid", assignUUID)
+}
+
+// assignUUID заполняет пустой uuid первичный ключ перед вставкой.
+// Ключи генерирует приложение, а не DEFAULT gen_random_uuid(), чтобы схема работала и на SQLite
+func assignUUID(db *gorm.DB) {
+	if db.Statement.Schema == nil {
+		return
+	}
+
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field == nil || field.FieldType != uuidType {
+		return
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			setUUID(db, field, reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		setUUID(db, field, rv)
+	}
+}
+
+func setUUID(db *gorm.DB, field *schema.Field, rv reflect.Value) {
+	if _, zero := field.ValueOf(db.Statement.Context, rv); !zero {
+		return
+	}
+	if err := field.Set(db.Statement.Context, rv, uuid.New()); err != nil {
+		db.AddError(err)
+	}
+}
@@ -8,20 +8,24 @@ import (
 	"os"
 )
 
+// Connect подключается к Postgres и отказывается работать на устаревшей схеме.
+// Схему меняет только команда migrate.
 func (d *Database) Connect() error {
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		return errors.New("DATABASE_URL is not set")
+	db, err := OpenPostgres()
+	if err != nil {
+		return err
 	}
 
-	var err error
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	migrator, err := NewMigrator(db)
 	if err != nil {
 		return err
 	}
 
-	err = db.AutoMigrate(&models.User{}, &models.Room{}, &models.Message{})
-	if err != nil {
+	if err := migrator.Check(); err != nil {
+		return err
+	}
+
+	if err := setup(db); err != nil {
 		return err
 	}
 
@@ -29,3 +33,39 @@ func (d *Database) Connect() error {
 
 	return nil
 }
+
+// OpenPostgres открывает соединение с базой из DATABASE_URL без проверки схемы
+func OpenPostgres() (*gorm.DB, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		return nil, errors.New("DATABASE_URL is not set")
+	}
+
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+}
+
+// setup регистрирует общие колбэки и связи моделей. Одинаково для Postgres и SQLite
+func setup(db *gorm.DB) error {
+	if err := registerCallbacks(db); err != nil {
+		return err
+	}
+
+	// room_members хранит данные участника, а не только пару ключей
+	if err := db.SetupJoinTable(&models.Room{}, "Members", &models.RoomMember{}); err != nil {
+		return err
+	}
+	return db.SetupJoinTable(&models.User{}, "Rooms", &models.RoomMember{})
+}
+
+// Close закрывает пул соединений с базой
+func (d *Database) Close() error {
+	if d.db == nil {
+		return nil
+	}
+
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"github.com/google/uuid"
+)
+
+// GetContactIDs возвращает друзей пользователя и тех, с кем он состоит хотя бы в одной общей комнате
+func (d *Database) GetContactIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	var roomContacts []uuid.UUID
+	err := d.db.Table("room_members AS mine").
+		Joins("JOIN room_members AS other ON other.room_id = mine.room_id").
+		Where("mine.user_id = ? AND other.user_id <> ?", userID, userID).
+		Distinct().
+		Pluck("other.user_id", &roomContacts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var friends []uuid.UUID
+	if err := d.friendIDsQuery(userID).Scan(&friends).Error; err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]bool, len(roomContacts))
+	for _, id := range roomContacts {
+		seen[id] = true
+	}
+	for _, id := range friends {
+		if !seen[id] {
+			roomContacts = append(roomContacts, id)
+		}
+	}
+	return roomContacts, nil
+}
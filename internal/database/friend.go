@@ -0,0 +1,121 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pairCondition условие на запись дружбы пары в любом направлении
+const pairCondition = "(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)"
+
+// GetFriendship возвращает заявку или дружбу пары пользователей в любом направлении
+func (d *Database) GetFriendship(user1ID, user2ID uuid.UUID) (*models.Friendship, error) {
+	var friendship models.Friendship
+	err := d.db.Where(pairCondition, user1ID, user2ID, user2ID, user1ID).
+		First(&friendship).Error
+	if err != nil {
+		return nil, err
+	}
+	return &friendship, nil
+}
+
+// CreateFriendRequest создает заявку; gorm.ErrDuplicatedKey, если у пары уже есть заявка или дружба.
+// Одновременные встречные заявки упираются в уникальный индекс пары, и вторая не создается.
+func (d *Database) CreateFriendRequest(friendship *models.Friendship) error {
+	result := d.db.Omit("Requester", "Addressee").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(friendship)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrDuplicatedKey
+	}
+	return nil
+}
+
+// AcceptFriendRequest принимает входящую заявку; gorm.ErrRecordNotFound, если ее нет
+func (d *Database) AcceptFriendRequest(requesterID, addresseeID uuid.UUID) error {
+	result := d.db.Model(&models.Friendship{}).
+		Where("requester_id = ? AND addressee_id = ? AND status = ?", requesterID, addresseeID, models.FriendshipPending).
+		Updates(map[string]interface{}{
+			"status":      models.FriendshipAccepted,
+			"accepted_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteFriendRequest удаляет заявку при отклонении или отмене; gorm.ErrRecordNotFound, если ее нет
+func (d *Database) DeleteFriendRequest(requesterID, addresseeID uuid.UUID) error {
+	result := d.db.Where("requester_id = ? AND addressee_id = ? AND status = ?", requesterID, addresseeID, models.FriendshipPending).
+		Delete(&models.Friendship{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RemoveFriend удаляет дружбу; gorm.ErrRecordNotFound, если пользователи не друзья
+func (d *Database) RemoveFriend(user1ID, user2ID uuid.UUID) error {
+	result := d.db.Where("status = ?", models.FriendshipAccepted).
+		Where(pairCondition, user1ID, user2ID, user2ID, user1ID).
+		Delete(&models.Friendship{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (d *Database) AreFriends(user1ID, user2ID uuid.UUID) (bool, error) {
+	var count int64
+	err := d.db.Model(&models.Friendship{}).
+		Where("status = ?", models.FriendshipAccepted).
+		Where(pairCondition, user1ID, user2ID, user2ID, user1ID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetFriends возвращает друзей пользователя по алфавиту
+func (d *Database) GetFriends(userID uuid.UUID) ([]models.User, error) {
+	var users []models.User
+	err := d.db.Where("id IN (?)", d.friendIDsQuery(userID)).
+		Order("username").
+		Find(&users).Error
+	return users, err
+}
+
+// GetFriendRequests возвращает входящие и исходящие заявки пользователя, новые первыми
+func (d *Database) GetFriendRequests(userID uuid.UUID) ([]models.Friendship, error) {
+	var requests []models.Friendship
+	err := d.db.Where("status = ?", models.FriendshipPending).
+		Where("requester_id = ? OR addressee_id = ?", userID, userID).
+		Preload("Requester").
+		Preload("Addressee").
+		Order("created_at DESC").
+		Find(&requests).Error
+	return requests, err
+}
+
+// friendIDsQuery подзапрос ID друзей пользователя
+func (d *Database) friendIDsQuery(userID uuid.UUID) *gorm.DB {
+	return d.db.Model(&models.Friendship{}).
+		Select("CASE WHEN requester_id = ? THEN addressee_id ELSE requester_id END", userID).
+		Where("status = ?", models.FriendshipAccepted).
+		Where("requester_id = ? OR addressee_id = ?", userID, userID)
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+)
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	db, err := OpenSQLite("file::memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	return db
+}
+
+func createTestUser(t *testing.T, db *Database, name string) uuid.UUID {
+	t.Helper()
+
+	user := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	if err := db.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+	return user.ID
+}
+
+// Встречная заявка, прошедшая проверку GetFriendship одновременно с первой,
+// упирается в уникальный индекс пары
+func TestCreateFriendRequestConflictsOnCounterRequest(t *testing.T) {
+	db := newTestDatabase(t)
+	alice, bob := createTestUser(t, db, "alice"), createTestUser(t, db, "bob")
+
+	first := &models.Friendship{RequesterID: alice, AddresseeID: bob, Status: models.FriendshipPending, CreatedAt: time.Now()}
+	if err := db.CreateFriendRequest(first); err != nil {
+		t.Fatalf("CreateFriendRequest: %v", err)
+	}
+
+	counter := &models.Friendship{RequesterID: bob, AddresseeID: alice, Status: models.FriendshipPending, CreatedAt: time.Now()}
+	if err := db.CreateFriendRequest(counter); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("expected gorm.ErrDuplicatedKey, got %v", err)
+	}
+
+	friendship, err := db.GetFriendship(alice, bob)
+	if err != nil {
+		t.Fatalf("GetFriendship: %v", err)
+	}
+	if friendship.RequesterID != alice {
+		t.Fatalf("the first request must survive, got requester %s", friendship.RequesterID)
+	}
+}
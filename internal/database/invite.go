@@ -0,0 +1,176 @@
+package database
+
+import (
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Алфавит и длина кода приглашения: 62^10 вариантов не перебрать
+const (
+	inviteCodeAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	inviteCodeLength   = 10
+)
+
+// SQLSTATE триггера check_room_member_limit (0005_room_member_limit_errcode)
+const roomMemberLimitCode = "DL001"
+
+var (
+	ErrInviteInvalid  = errors.New("invite is invalid or expired")
+	ErrRoomFull       = errors.New("room is full")
+	ErrBannedFromRoom = errors.New("you are banned from this room")
+)
+
+// CreateRoomInvite сохраняет приглашение, пустой код генерируется
+func (d *Database) CreateRoomInvite(invite *models.RoomInvite) error {
+	if invite.Code == "" {
+		code, err := newInviteCode()
+		if err != nil {
+			return err
+		}
+		invite.Code = code
+	}
+	return d.db.Omit("Room", "Creator").Create(invite).Error
+}
+
+// GetRoomInvite возвращает приглашение с комнатой и ее участниками
+func (d *Database) GetRoomInvite(code string) (*models.RoomInvite, error) {
+	var invite models.RoomInvite
+	if err := d.db.Preload("Room.Members").First(&invite, "code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// GetRoomInvites возвращает неотозванные приглашения комнаты, новые первыми
+func (d *Database) GetRoomInvites(roomID uuid.UUID) ([]models.RoomInvite, error) {
+	var invites []models.RoomInvite
+	err := d.db.Where("room_id = ? AND revoked_at IS NULL", roomID).
+		Preload("Creator").
+		Order("created_at DESC").
+		Find(&invites).Error
+	return invites, err
+}
+
+// RevokeRoomInvite отзывает приглашение; gorm.ErrRecordNotFound, если его нет или оно уже отозвано
+func (d *Database) RevokeRoomInvite(roomID uuid.UUID, code string) error {
+	result := d.db.Model(&models.RoomInvite{}).
+		Where("code = ? AND room_id = ? AND revoked_at IS NULL", code, roomID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AcceptRoomInvite добавляет пользователя в комнату по приглашению и засчитывает использование.
+// Если пользователь уже в комнате, приглашение не расходуется. Возвращает ErrInviteInvalid
+// для несуществующего, отозванного, истекшего или исчерпанного приглашения, ErrBannedFromRoom
+// для забаненного пользователя и ErrRoomFull, если в комнате нет мест. Все проверки идут
+// под блокировкой комнаты, поэтому параллельные принятия не обходят их.
+func (d *Database) AcceptRoomInvite(code string, userID uuid.UUID) (*models.RoomInvite, error) {
+	var invite models.RoomInvite
+
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invite, "code = ?", code).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInviteInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		// Блокировка комнаты выстраивает параллельные вступления в очередь,
+		// иначе подсчет участников в check_room_member_limit может разойтись
+		var room models.Room
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&room, "id = ?", invite.RoomID).Error; err != nil {
+			return err
+		}
+		invite.Room = room
+
+		var memberCount int64
+		err = tx.Model(&models.RoomMember{}).
+			Where("room_id = ? AND user_id = ?", room.ID, userID).
+			Count(&memberCount).Error
+		if err != nil {
+			return err
+		}
+		if memberCount > 0 {
+			return nil
+		}
+
+		now := time.Now()
+		if !invite.IsUsable(now) {
+			return ErrInviteInvalid
+		}
+
+		var banCount int64
+		err = tx.Model(&models.RoomBan{}).
+			Where("room_id = ? AND user_id = ?", room.ID, userID).
+			Count(&banCount).Error
+		if err != nil {
+			return err
+		}
+		if banCount > 0 {
+			return ErrBannedFromRoom
+		}
+
+		var count int64
+		if err := tx.Model(&models.RoomMember{}).Where("room_id = ?", room.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(room.MaxMembers) {
+			return ErrRoomFull
+		}
+
+		member := &models.RoomMember{
+			UserID:     userID,
+			RoomID:     room.ID,
+			JoinedAt:   now,
+			Role:       models.RoleMember,
+			LastReadAt: now,
+		}
+		if err := tx.Create(member).Error; err != nil {
+			return memberLimitError(err)
+		}
+
+		invite.Uses++
+		return tx.Model(&invite).UpdateColumn("uses", gorm.Expr("uses + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// memberLimitError переводит ошибку триггера check_room_member_limit в ErrRoomFull
+func memberLimitError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == roomMemberLimitCode {
+		return ErrRoomFull
+	}
+	return err
+}
+
+func newInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	// 256 не делится на 62, небольшой перекос распределения для кода приглашения не важен
+	code := make([]byte, inviteCodeLength)
+	for i, b := range buf {
+		code[i] = inviteCodeAlphabet[int(b)%len(inviteCodeAlphabet)]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/thereayou/discord-lite/internal/models"
+)
+
+// newTestInvite создает комнату владельца с приглашением на maxUses использований
+func newTestInvite(t *testing.T, db *Database, ownerID uuid.UUID, maxMembers, maxUses int) *models.RoomInvite {
+	t.Helper()
+
+	room := &models.Room{Name: "private", Type: "group", CreatedBy: ownerID, MaxMembers: maxMembers, IsPrivate: true, CreatedAt: time.Now()}
+	if err := db.CreateRoom(room); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	if err := db.AddUserToRoom(ownerID.String(), room.ID.String()); err != nil {
+		t.Fatalf("AddUserToRoom: %v", err)
+	}
+
+	invite := &models.RoomInvite{RoomID: room.ID, CreatedBy: ownerID, MaxUses: maxUses, CreatedAt: time.Now()}
+	if err := db.CreateRoomInvite(invite); err != nil {
+		t.Fatalf("CreateRoomInvite: %v", err)
+	}
+	return invite
+}
+
+func TestAcceptRoomInviteByMemberDoesNotConsumeIt(t *testing.T) {
+	db := newTestDatabase(t)
+	owner := createTestUser(t, db, "owner")
+	invite := newTestInvite(t, db, owner, 10, 1)
+
+	accepted, err := db.AcceptRoomInvite(invite.Code, owner)
+	if err != nil {
+		t.Fatalf("AcceptRoomInvite: %v", err)
+	}
+	if accepted.RoomID != invite.RoomID || accepted.Uses != 0 {
+		t.Fatalf("member must not consume the invite, uses %d", accepted.Uses)
+	}
+
+	// Единственное использование осталось для нового участника
+	if _, err := db.AcceptRoomInvite(invite.Code, createTestUser(t, db, "bob")); err != nil {
+		t.Fatalf("AcceptRoomInvite: %v", err)
+	}
+}
+
+func TestAcceptRoomInviteRejectsBannedUser(t *testing.T) {
+	db := newTestDatabase(t)
+	owner, bob := createTestUser(t, db, "owner"), createTestUser(t, db, "bob")
+	invite := newTestInvite(t, db, owner, 10, 0)
+
+	if err := db.BanUser(&models.RoomBan{RoomID: invite.RoomID, UserID: bob, BannedBy: owner, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("BanUser: %v", err)
+	}
+
+	if _, err := db.AcceptRoomInvite(invite.Code, bob); !errors.Is(err, ErrBannedFromRoom) {
+		t.Fatalf("expected ErrBannedFromRoom, got %v", err)
+	}
+	if isMember, _ := db.IsRoomMember(bob.String(), invite.RoomID.String()); isMember {
+		t.Fatal("banned user joined the room")
+	}
+}
+
+func TestAcceptRoomInviteRejectsFullRoom(t *testing.T) {
+	db := newTestDatabase(t)
+	owner := createTestUser(t, db, "owner")
+	invite := newTestInvite(t, db, owner, 2, 0)
+
+	if _, err := db.AcceptRoomInvite(invite.Code, createTestUser(t, db, "bob")); err != nil {
+		t.Fatalf("AcceptRoomInvite: %v", err)
+	}
+	if _, err := db.AcceptRoomInvite(invite.Code, createTestUser(t, db, "carol")); !errors.Is(err, ErrRoomFull) {
+		t.Fatalf("expected ErrRoomFull, got %v", err)
+	}
+}
+
+func TestMemberLimitErrorMatchesSQLSTATE(t *testing.T) {
+	if err := memberLimitError(fmt.Errorf("insert: %w", &pgconn.PgError{Code: roomMemberLimitCode})); !errors.Is(err, ErrRoomFull) {
+		t.Fatalf("expected ErrRoomFull, got %v", err)
+	}
+
+	other := &pgconn.PgError{Code: "P0001", Message: "Room member limit exceeded"}
+	if err := memberLimitError(other); err != other {
+		t.Fatalf("only the dedicated SQLSTATE means a full room, got %v", err)
+	}
+}
@@ -3,39 +3,122 @@ package database
 import (
 	"github.com/google/uuid"
 	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
+// SaveMessage сохраняет сообщение; ответ в треде обновляет счетчик корневого сообщения
 func (d *Database) SaveMessage(message *models.Message) error {
-	return d.db.Create(message).Error
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(message).Error; err != nil {
+			return err
+		}
+		return incrementThreadReplies(tx, message)
+	})
 }
 
 func (d *Database) GetMessage(id string) (*models.Message, error) {
 	var message models.Message
-	if err := d.db.First(&message, "id = ?", id).Error; err != nil {
+	if err := d.db.Preload("Attachments").Preload("ReplyTo", unscoped).Preload("ReplyTo.User").First(&message, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// GetAttachmentMessage возвращает сообщение, к которому относится файл или превью с этим URL.
+// gorm.ErrRecordNotFound, если такого вложения нет или сообщение удалено.
+func (d *Database) GetAttachmentMessage(url string) (*models.Message, error) {
+	var message models.Message
+	err := d.db.Where("id IN (?)", d.db.Model(&models.MessageAttachment{}).
+		Select("message_id").
+		Where("file_url = ? OR thumbnail_url = ?", url, url)).
+		First(&message).Error
+	if err != nil {
 		return nil, err
 	}
 	return &message, nil
 }
 
+// SaveMessageWithAttachments сохраняет сообщение и его вложения в одной транзакции
+func (d *Database) SaveMessageWithAttachments(message *models.Message, attachments []models.MessageAttachment) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(message).Error; err != nil {
+			return err
+		}
+
+		if err := incrementThreadReplies(tx, message); err != nil {
+			return err
+		}
+
+		for i := range attachments {
+			attachments[i].MessageID = message.ID
+		}
+
+		if len(attachments) > 0 {
+			if err := tx.Create(&attachments).Error; err != nil {
+				return err
+			}
+		}
+
+		message.Attachments = attachments
+		return nil
+	})
+}
+
 func (d *Database) UpdateMessage(message *models.Message) error {
-	return d.db.Save(message).Error
+	return d.db.Omit(clause.Associations).Save(message).Error
 }
 
-func (d *Database) DeleteMessage(id string) error {
-	return d.db.Delete(&models.Message{}, "id = ?", id).Error
+// DeleteMessage мягко удаляет сообщение и записывает действие в журнал модерации
+func (d *Database) DeleteMessage(message *models.Message, actorID uuid.UUID) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(message).
+			Where("deleted_at IS NULL").
+			Updates(map[string]interface{}{
+				"deleted_at": now,
+				"deleted_by": actorID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		message.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		message.DeletedBy = &actorID
+
+		if err := changeThreadReplies(tx, message, -1); err != nil {
+			return err
+		}
+
+		return writeAuditLog(tx, message, actorID, models.AuditActionDelete)
+	})
 }
 
-// GetRoomMessages получает сообщения комнаты с пагинацией
-func (d *Database) GetRoomMessages(roomID string, limit int, beforeID *uuid.UUID) ([]models.Message, error) {
+// GetRoomMessages получает сообщения комнаты с пагинацией, без ответов в тредах.
+// Удаленные сообщения возвращаются как есть, чтобы клиент показал заглушку.
+// Сообщения пользователей, заблокированных viewerID, не возвращаются.
+func (d *Database) GetRoomMessages(roomID string, viewerID uuid.UUID, limit int, beforeID *uuid.UUID) ([]models.Message, error) {
+	query := d.db.Unscoped().Where("room_id = ? AND thread_id IS NULL", roomID)
+	return d.getMessagesPage(query, viewerID, limit, beforeID)
+}
+
+// getMessagesPage загружает страницу сообщений до beforeID в хронологическом порядке
+func (d *Database) getMessagesPage(query *gorm.DB, viewerID uuid.UUID, limit int, beforeID *uuid.UUID) ([]models.Message, error) {
 	var messages []models.Message
 
-	query := d.db.Where("room_id = ?", roomID)
+	if viewerID != uuid.Nil {
+		query = query.Where("user_id NOT IN (?)",
+			d.db.Model(&models.UserBlock{}).Select("blocked_id").Where("blocker_id = ?", viewerID))
+	}
 
 	// Если указан beforeID, получаем сообщения до него
 	if beforeID != nil {
 		var beforeMsg models.Message
-		if err := d.db.First(&beforeMsg, "id = ?", beforeID).Error; err == nil {
+		if err := d.db.Unscoped().First(&beforeMsg, "id = ?", beforeID).Error; err == nil {
 			query = query.Where("created_at < ?", beforeMsg.CreatedAt)
 		}
 	}
@@ -44,6 +127,9 @@ func (d *Database) GetRoomMessages(roomID string, limit int, beforeID *uuid.UUID
 		Order("created_at DESC").
 		Limit(limit).
 		Preload("User").
+		Preload("Attachments").
+		Preload("ReplyTo", unscoped).
+		Preload("ReplyTo.User").
 		Find(&messages).Error
 
 	if err != nil {
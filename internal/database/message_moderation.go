@@ -0,0 +1,142 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+)
+
+// Сколько удаленных сообщений очищается за одну транзакцию
+const purgeBatchSize = 500
+
+// unscoped снимает фильтр мягкого удаления для Preload
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+func writeAuditLog(tx *gorm.DB, message *models.Message, actorID uuid.UUID, action string) error {
+	return tx.Create(&models.MessageAuditLog{
+		RoomID:    message.RoomID,
+		MessageID: message.ID,
+		AuthorID:  message.UserID,
+		ActorID:   actorID,
+		Action:    action,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// GetDeletedMessage возвращает мягко удаленное сообщение
+func (d *Database) GetDeletedMessage(id string) (*models.Message, error) {
+	var message models.Message
+	err := d.db.Unscoped().
+		Where("deleted_at IS NOT NULL").
+		First(&message, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// RestoreMessage восстанавливает удаленное сообщение и записывает действие в журнал модерации
+func (d *Database) RestoreMessage(message *models.Message, actorID uuid.UUID) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(message).
+			Where("deleted_at IS NOT NULL").
+			Updates(map[string]interface{}{
+				"deleted_at": nil,
+				"deleted_by": nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		message.DeletedAt = gorm.DeletedAt{}
+		message.DeletedBy = nil
+
+		if err := changeThreadReplies(tx, message, 1); err != nil {
+			return err
+		}
+
+		return writeAuditLog(tx, message, actorID, models.AuditActionRestore)
+	})
+}
+
+// GetMessageAuditLog возвращает журнал модерации комнаты, свежие записи первыми
+func (d *Database) GetMessageAuditLog(roomID uuid.UUID, limit int, before *time.Time) ([]models.MessageAuditLog, error) {
+	var entries []models.MessageAuditLog
+
+	query := d.db.Where("room_id = ?", roomID)
+	if before != nil {
+		query = query.Where("created_at < ?", *before)
+	}
+
+	err := query.
+		Order("created_at DESC").
+		Limit(limit).
+		Preload("Actor").
+		Find(&entries).Error
+	return entries, err
+}
+
+// PurgeDeletedMessages стирает содержимое, вложения, реакции и историю правок сообщений, удаленных раньше deletedBefore.
+// Сами строки остаются заглушками, чтобы не ломать ответы и треды.
+// Возвращает удаленные вложения, чтобы вызывающий убрал файлы из хранилища.
+func (d *Database) PurgeDeletedMessages(deletedBefore time.Time) (int, []models.MessageAttachment, error) {
+	var purged int
+	var attachments []models.MessageAttachment
+
+	for {
+		var ids []uuid.UUID
+		err := d.db.Unscoped().Model(&models.Message{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			Where("content <> '' OR EXISTS (SELECT 1 FROM message_attachments a WHERE a.message_id = messages.id) "+
+				"OR EXISTS (SELECT 1 FROM message_revisions r WHERE r.message_id = messages.id)").
+			Limit(purgeBatchSize).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return purged, attachments, err
+		}
+
+		if len(ids) == 0 {
+			return purged, attachments, nil
+		}
+
+		err = d.db.Transaction(func(tx *gorm.DB) error {
+			var batch []models.MessageAttachment
+			if err := tx.Where("message_id IN ?", ids).Find(&batch).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Where("message_id IN ?", ids).Delete(&models.MessageAttachment{}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Where("message_id IN ?", ids).Delete(&models.MessageReaction{}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Where("message_id IN ?", ids).Delete(&models.MessageRevision{}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Unscoped().Model(&models.Message{}).
+				Where("id IN ?", ids).
+				Update("content", "").Error; err != nil {
+				return err
+			}
+
+			attachments = append(attachments, batch...)
+			return nil
+		})
+		if err != nil {
+			return purged, attachments, err
+		}
+
+		purged += len(ids)
+	}
+}
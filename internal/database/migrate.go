@@ -0,0 +1,296 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Имя файла миграции: 0001_name.up.sql и парный 0001_name.down.sql
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Ключ advisory lock Postgres: несколько инстансов не применяют одну миграцию дважды
+const migrationLockKey = 7316001
+
+var (
+	ErrSchemaOutdated = errors.New("database schema is out of date, run `migrate up`")
+	ErrSchemaTooNew   = errors.New("database schema is newer than this build")
+)
+
+// Migration версия схемы с SQL для применения и отката
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus миграция и время её применения; AppliedAt nil, если она еще не применена
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration строка schema_migrations
+type schemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator применяет и откатывает встроенные в бинарник миграции Postgres
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// loadMigrations читает пары up/down и сортирует их по версии
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	paths, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, path := range paths {
+		file := path[len("migrations/"):]
+		match := migrationFileRe.FindStringSubmatch(file)
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", file)
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", file, err)
+		}
+
+		body, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down scripts", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Status возвращает все известные миграции с отметкой о применении
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		result[i] = MigrationStatus{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			result[i].AppliedAt = &at
+		}
+	}
+	return result, nil
+}
+
+// Check проверяет, что схема базы совпадает с последней миграцией сборки.
+// Сервер не меняет схему сам, для этого есть команда migrate.
+func (m *Migrator) Check() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int64]bool, len(m.migrations))
+	pending := 0
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		if _, ok := applied[migration.Version]; !ok {
+			pending++
+		}
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: unknown migration %d is applied", ErrSchemaTooNew, version)
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migration(s)", ErrSchemaOutdated, pending)
+	}
+	return nil
+}
+
+// Up применяет все еще не примененные миграции по порядку и возвращает их число
+func (m *Migrator) Up() (int, error) {
+	if err := m.ensureTable(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		ran := false
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := lockMigrations(tx); err != nil {
+				return err
+			}
+
+			// Другой инстанс мог успеть применить миграцию, пока мы ждали блокировку
+			var n int64
+			if err := tx.Model(&schemaMigration{}).Where("version = ?", migration.Version).Count(&n).Error; err != nil {
+				return err
+			}
+			if n > 0 {
+				return nil
+			}
+
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+
+			ran = true
+			return tx.Create(&schemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return count, err
+		}
+
+		if ran {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Down откатывает steps последних примененных миграций и возвращает их число
+func (m *Migrator) Down(steps int) (int, error) {
+	if err := m.ensureTable(); err != nil {
+		return 0, err
+	}
+
+	byVersion := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	count := 0
+	for count < steps {
+		done := false
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := lockMigrations(tx); err != nil {
+				return err
+			}
+
+			var last schemaMigration
+			err := tx.Order("version DESC").Limit(1).Find(&last).Error
+			if err != nil {
+				return err
+			}
+			if last.Version == 0 {
+				done = true
+				return nil
+			}
+
+			migration, ok := byVersion[last.Version]
+			if !ok {
+				return fmt.Errorf("%w: cannot roll back unknown migration %d", ErrSchemaTooNew, last.Version)
+			}
+
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+
+			return tx.Delete(&schemaMigration{}, "version = ?", last.Version).Error
+		})
+		if err != nil {
+			return count, err
+		}
+
+		if done {
+			break
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	return m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`).Error
+}
+
+// applied возвращает примененные версии; без schema_migrations схема считается пустой
+func (m *Migrator) applied() (map[int64]time.Time, error) {
+	result := make(map[int64]time.Time)
+	if !m.db.Migrator().HasTable(&schemaMigration{}) {
+		return result, nil
+	}
+
+	var rows []schemaMigration
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.Version] = row.AppliedAt
+	}
+	return result, nil
+}
+
+// lockMigrations берет advisory lock до конца транзакции
+func lockMigrations(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error
+}
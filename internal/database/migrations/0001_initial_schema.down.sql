@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS trigger_check_room_limit ON room_members;
+DROP FUNCTION IF EXISTS check_room_member_limit();
+
+DROP TABLE IF EXISTS user_blocks;
+DROP TABLE IF EXISTS room_bans;
+DROP TABLE IF EXISTS message_audit_logs;
+DROP TABLE IF EXISTS message_revisions;
+DROP TABLE IF EXISTS message_reactions;
+DROP TABLE IF EXISTS message_attachments;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS room_members;
+DROP TABLE IF EXISTS rooms;
+DROP TABLE IF EXISTS users;
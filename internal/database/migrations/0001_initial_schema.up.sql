@@ -0,0 +1,171 @@
+-- Базовая схема. IF NOT EXISTS позволяет принять базу, созданную
+-- раньше через init.sql и AutoMigrate, без потери данных: существующие таблицы
+-- не пересоздаются, а недостающие в них колонки добавляются через ADD COLUMN IF NOT EXISTS
+
+CREATE TABLE IF NOT EXISTS users (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    username      TEXT NOT NULL,
+    email         TEXT NOT NULL,
+    password_hash TEXT NOT NULL,
+    avatar_url    TEXT,
+    last_seen_at  TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS rooms (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name        TEXT NOT NULL,
+    type        TEXT NOT NULL CONSTRAINT chk_rooms_type CHECK (type IN ('direct', 'group')),
+    max_members BIGINT DEFAULT 20,
+    created_by  UUID,
+    created_at  TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS room_members (
+    user_id      UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    room_id      UUID NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    joined_at    TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    role         VARCHAR(20) DEFAULT 'member' CONSTRAINT chk_room_members_role CHECK (role IN ('member', 'moderator', 'admin')),
+    last_read_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, room_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_members_room_id ON room_members (room_id);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    room_id        UUID NOT NULL REFERENCES rooms (id),
+    user_id        UUID NOT NULL REFERENCES users (id),
+    content        TEXT NOT NULL,
+    type           TEXT DEFAULT 'text',
+    created_at     TIMESTAMPTZ,
+    edited_at      TIMESTAMPTZ,
+    revision_count BIGINT NOT NULL DEFAULT 0,
+    deleted_at     TIMESTAMPTZ,
+    deleted_by     UUID,
+    reply_to_id    UUID REFERENCES messages (id) ON DELETE SET NULL,
+    thread_id      UUID REFERENCES messages (id) ON DELETE CASCADE,
+    reply_count    BIGINT NOT NULL DEFAULT 0,
+    last_reply_at  TIMESTAMPTZ,
+    -- simple не стеммит, поэтому поиск одинаково работает для любого языка
+    search_vector  TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED
+);
+
+-- В messages из init.sql нет колонок правок, тредов, ответов и поиска
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS revision_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_by UUID;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to_id UUID REFERENCES messages (id) ON DELETE SET NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS thread_id UUID REFERENCES messages (id) ON DELETE CASCADE;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS last_reply_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_messages_room_created ON messages (room_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_messages_reply_to_id ON messages (reply_to_id);
+CREATE INDEX IF NOT EXISTS idx_messages_thread_id ON messages (thread_id);
+CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector);
+
+CREATE TABLE IF NOT EXISTS message_attachments (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id    UUID NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    file_name     VARCHAR(255) NOT NULL,
+    file_size     BIGINT NOT NULL,
+    file_type     VARCHAR(100),
+    file_url      VARCHAR(500) NOT NULL,
+    thumbnail_url VARCHAR(500),
+    created_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_attachments_message_id ON message_attachments (message_id);
+
+CREATE TABLE IF NOT EXISTS message_reactions (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id UUID NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    emoji      VARCHAR(10) NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_unique ON message_reactions (message_id, user_id, emoji);
+
+CREATE TABLE IF NOT EXISTS message_revisions (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id UUID NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    content    TEXT NOT NULL,
+    edited_by  UUID NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_revisions_message_created ON message_revisions (message_id, created_at);
+
+-- Журнал модерации не ссылается на messages, чтобы переживать очистку сообщений
+CREATE TABLE IF NOT EXISTS message_audit_logs (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    room_id    UUID NOT NULL,
+    message_id UUID NOT NULL,
+    author_id  UUID NOT NULL,
+    actor_id   UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    action     VARCHAR(20) NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_audit_room_created ON message_audit_logs (room_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_message_audit_logs_message_id ON message_audit_logs (message_id);
+
+CREATE TABLE IF NOT EXISTS room_bans (
+    room_id    UUID NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    banned_by  UUID NOT NULL,
+    reason     VARCHAR(500),
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (room_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CONSTRAINT check_not_self_block CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id);
+
+-- Лимит участников проверяется и в базе, чтобы параллельные входы не превысили max_members
+CREATE OR REPLACE FUNCTION check_room_member_limit()
+    RETURNS TRIGGER AS $$
+DECLARE
+    current_count INT;
+    max_count INT;
+BEGIN
+    SELECT COUNT(*), r.max_members INTO current_count, max_count
+    FROM room_members rm
+             JOIN rooms r ON r.id = rm.room_id
+    WHERE rm.room_id = NEW.room_id
+    GROUP BY r.max_members;
+
+    IF current_count >= max_count THEN
+        RAISE EXCEPTION 'Room member limit exceeded';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_check_room_limit ON room_members;
+CREATE TRIGGER trigger_check_room_limit
+    BEFORE INSERT ON room_members
+    FOR EACH ROW
+EXECUTE FUNCTION check_room_member_limit();
+
+-- Объекты из init.sql, которые приложение не использует
+DROP VIEW IF EXISTS room_last_messages;
+DROP VIEW IF EXISTS unread_counts;
+DROP TRIGGER IF EXISTS trigger_update_last_seen ON messages;
+DROP FUNCTION IF EXISTS update_user_last_seen();
+DROP FUNCTION IF EXISTS create_or_get_direct_room(UUID, UUID);
@@ -0,0 +1,4 @@
+ALTER TABLE room_members DROP COLUMN IF EXISTS muted_until;
+ALTER TABLE room_members DROP COLUMN IF EXISTS muted;
+
+DROP TABLE IF EXISTS push_tokens;
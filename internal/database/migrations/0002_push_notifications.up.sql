@@ -0,0 +1,19 @@
+-- Push-токены устройств. Таблица могла остаться от init.sql, поэтому IF NOT EXISTS;
+-- подписка Web Push длиннее 500 символов, token расширяется до TEXT
+CREATE TABLE IF NOT EXISTS push_tokens (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL CONSTRAINT fk_push_tokens_user REFERENCES users (id) ON DELETE CASCADE,
+    token      TEXT NOT NULL,
+    platform   VARCHAR(20) NOT NULL CHECK (platform IN ('web', 'ios', 'android')),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, token)
+);
+
+ALTER TABLE push_tokens ALTER COLUMN token TYPE TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_push_tokens_user_id ON push_tokens (user_id);
+
+-- Отключение уведомлений комнаты; muted_until NULL при muted = true означает бессрочно
+ALTER TABLE room_members ADD COLUMN IF NOT EXISTS muted BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE room_members ADD COLUMN IF NOT EXISTS muted_until TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS friends_only_direct;
+
+DROP TABLE IF EXISTS friendships;
@@ -0,0 +1,19 @@
+-- Заявки в друзья и дружба: одна запись на пару, status pending или accepted
+CREATE TABLE IF NOT EXISTS friendships (
+    requester_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    addressee_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status       VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted')),
+    created_at   TIMESTAMPTZ,
+    accepted_at  TIMESTAMPTZ,
+    PRIMARY KEY (requester_id, addressee_id),
+    CONSTRAINT check_not_self_friend CHECK (requester_id <> addressee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_friendships_addressee_id ON friendships (addressee_id);
+
+-- Встречные заявки не создают вторую запись для той же пары
+CREATE UNIQUE INDEX IF NOT EXISTS idx_friendships_pair
+    ON friendships (LEAST(requester_id, addressee_id), GREATEST(requester_id, addressee_id));
+
+-- Личные комнаты только с друзьями
+ALTER TABLE users ADD COLUMN IF NOT EXISTS friends_only_direct BOOLEAN NOT NULL DEFAULT false;
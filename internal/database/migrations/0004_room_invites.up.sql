@@ -0,0 +1,16 @@
+-- Приватные группы: вступление только по приглашению
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT false;
+
+-- Коды приглашений; max_uses 0 и expires_at NULL — без ограничений
+CREATE TABLE IF NOT EXISTS room_invites (
+    code       VARCHAR(16) PRIMARY KEY,
+    room_id    UUID NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+    created_by UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    max_uses   INT NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+    uses       INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_invites_room_id ON room_invites (room_id);
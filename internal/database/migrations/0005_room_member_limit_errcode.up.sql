@@ -0,0 +1,21 @@
+-- Превышение лимита участников получает собственный SQLSTATE, чтобы приложение
+-- распознавало его по коду, а не по тексту сообщения
+CREATE OR REPLACE FUNCTION check_room_member_limit()
+    RETURNS TRIGGER AS $$
+DECLARE
+    current_count INT;
+    max_count INT;
+BEGIN
+    SELECT COUNT(*), r.max_members INTO current_count, max_count
+    FROM room_members rm
+             JOIN rooms r ON r.id = rm.room_id
+    WHERE rm.room_id = NEW.room_id
+    GROUP BY r.max_members;
+
+    IF current_count >= max_count THEN
+        RAISE EXCEPTION 'Room member limit exceeded' USING ERRCODE = 'DL001';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SavePushToken регистрирует токен устройства; повторная регистрация обновляет платформу
+func (d *Database) SavePushToken(token *models.PushToken) error {
+	return d.db.Omit("User").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "token"}},
+			DoUpdates: clause.AssignmentColumns([]string{"platform", "updated_at"}),
+		}).
+		Create(token).Error
+}
+
+// DeletePushToken удаляет токен устройства пользователя
+func (d *Database) DeletePushToken(userID uuid.UUID, token string) error {
+	result := d.db.Where("user_id = ? AND token = ?", userID, token).Delete(&models.PushToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeletePushTokensByID удаляет токены, которые провайдер признал недействительными
+func (d *Database) DeletePushTokensByID(ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.db.Where("id IN ?", ids).Delete(&models.PushToken{}).Error
+}
+
+// GetPushTokens возвращает токены устройств пользователей
+func (d *Database) GetPushTokens(userIDs []uuid.UUID) ([]models.PushToken, error) {
+	var tokens []models.PushToken
+	if len(userIDs) == 0 {
+		return tokens, nil
+	}
+	err := d.db.Where("user_id IN ?", userIDs).Find(&tokens).Error
+	return tokens, err
+}
+
+// SetRoomMute включает или выключает уведомления комнаты для участника
+func (d *Database) SetRoomMute(userID, roomID uuid.UUID, muted bool, until *time.Time) error {
+	if !muted {
+		until = nil
+	}
+
+	result := d.db.Model(&models.RoomMember{}).
+		Where("user_id = ? AND room_id = ?", userID, roomID).
+		Updates(map[string]interface{}{"muted": muted, "muted_until": until})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetNotifiableMembers возвращает участников комнаты, которым нужно уведомление о сообщении senderID:
+// кроме самого отправителя, тех, кто отключил уведомления, и тех, кто заблокировал отправителя
+func (d *Database) GetNotifiableMembers(roomID, senderID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := d.db.Model(&models.RoomMember{}).
+		Where("room_id = ? AND user_id <> ?", roomID, senderID).
+		Where("(muted = ? OR (muted_until IS NOT NULL AND muted_until <= ?))", false, time.Now()).
+		Where("user_id NOT IN (?)", d.db.Model(&models.UserBlock{}).Select("blocker_id").Where("blocked_id = ?", senderID)).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
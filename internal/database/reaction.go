@@ -0,0 +1,72 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReactionCount агрегированная реакция на сообщение
+type ReactionCount struct {
+	MessageID   uuid.UUID
+	Emoji       string
+	Count       int
+	ReactedByMe bool
+}
+
+// AddReaction добавляет реакцию, повторная реакция тем же emoji игнорируется.
+// Возвращает false, если такая реакция уже была.
+func (d *Database) AddReaction(reaction *models.MessageReaction) (bool, error) {
+	result := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (d *Database) RemoveReaction(messageID, userID uuid.UUID, emoji string) error {
+	result := d.db.
+		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&models.MessageReaction{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CountReactions возвращает количество реакций emoji на сообщение
+func (d *Database) CountReactions(messageID uuid.UUID, emoji string) (int64, error) {
+	var count int64
+	err := d.db.Model(&models.MessageReaction{}).
+		Where("message_id = ? AND emoji = ?", messageID, emoji).
+		Count(&count).Error
+	return count, err
+}
+
+// GetReactionCounts группирует реакции по сообщениям и emoji в порядке первой реакции
+func (d *Database) GetReactionCounts(messageIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]ReactionCount, error) {
+	result := make(map[uuid.UUID][]ReactionCount)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []ReactionCount
+	err := d.db.Model(&models.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, MAX(CASE WHEN user_id = ? THEN 1 ELSE 0 END) = 1 AS reacted_by_me", userID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("MIN(created_at)").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.MessageID] = append(result[row.MessageID], row)
+	}
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"time"
+)
+
+// MarkRoomRead сдвигает last_read_at участника вперед, но никогда назад.
+// Возвращает false, если отметка уже была не раньше readAt.
+func (d *Database) MarkRoomRead(userID, roomID uuid.UUID, readAt time.Time) (bool, error) {
+	result := d.db.Model(&models.RoomMember{}).
+		Where("user_id = ? AND room_id = ? AND (last_read_at IS NULL OR last_read_at < ?)", userID, roomID, readAt).
+		Update("last_read_at", readAt)
+	return result.RowsAffected > 0, result.Error
+}
+
+// unreadBlockedFilter исключает сообщения заблокированных читателем пользователей,
+// как и лента комнаты: непрочитанным считается только то, что он увидит
+const unreadBlockedFilter = "m.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = rm.user_id)"
+
+// GetUnreadCounts возвращает количество непрочитанных сообщений по всем комнатам пользователя
+func (d *Database) GetUnreadCounts(userID uuid.UUID) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		RoomID      uuid.UUID
+		UnreadCount int64
+	}
+
+	err := d.db.Table("room_members rm").
+		Select("rm.room_id, COUNT(m.id) AS unread_count").
+		Joins("LEFT JOIN messages m ON m.room_id = rm.room_id AND m.created_at > rm.last_read_at AND m.user_id <> rm.user_id AND m.deleted_at IS NULL AND m.thread_id IS NULL AND "+unreadBlockedFilter).
+		Where("rm.user_id = ?", userID).
+		Group("rm.room_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.RoomID] = row.UnreadCount
+	}
+	return counts, nil
+}
+
+// GetUnreadCount возвращает количество непрочитанных сообщений пользователя в комнате
+func (d *Database) GetUnreadCount(userID, roomID uuid.UUID) (int64, error) {
+	var count int64
+	err := d.db.Table("messages m").
+		Joins("JOIN room_members rm ON rm.room_id = m.room_id AND rm.user_id = ?", userID).
+		Where("m.room_id = ? AND m.created_at > rm.last_read_at AND m.user_id <> ? AND m.deleted_at IS NULL AND m.thread_id IS NULL", roomID, userID).
+		Where(unreadBlockedFilter).
+		Count(&count).Error
+	return count, err
+}
+
+// GetRoomReadStates возвращает отметки прочтения всех участников комнаты
+func (d *Database) GetRoomReadStates(roomID uuid.UUID) ([]models.RoomMember, error) {
+	var members []models.RoomMember
+	err := d.db.Where("room_id = ?", roomID).
+		Order("last_read_at DESC").
+		Find(&members).Error
+	return members, err
+}
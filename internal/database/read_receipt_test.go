@@ -0,0 +1,64 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+)
+
+// Ответы в тредах и сообщения заблокированных пользователей не попадают в ленту,
+// поэтому не должны увеличивать счетчик непрочитанных
+func TestUnreadCountsSkipThreadRepliesAndBlockedSenders(t *testing.T) {
+	db := newTestDatabase(t)
+	alice, bob, carol := createTestUser(t, db, "alice"), createTestUser(t, db, "bob"), createTestUser(t, db, "carol")
+
+	room := &models.Room{Name: "general", Type: "group", CreatedBy: alice, CreatedAt: time.Now()}
+	if err := db.CreateRoom(room); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	for _, userID := range []uuid.UUID{alice, bob, carol} {
+		if err := db.AddUserToRoom(userID.String(), room.ID.String()); err != nil {
+			t.Fatalf("AddUserToRoom: %v", err)
+		}
+	}
+
+	if err := db.BlockUser(&models.UserBlock{BlockerID: alice, BlockedID: carol, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+
+	sentAt := time.Now().Add(time.Hour)
+	send := func(userID uuid.UUID, threadID *uuid.UUID) *models.Message {
+		message := &models.Message{ID: uuid.New(), RoomID: room.ID, UserID: userID, Content: "hi", ThreadID: threadID, CreatedAt: sentAt}
+		if err := db.SaveMessage(message); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+		return message
+	}
+
+	root := send(bob, nil)
+	send(bob, &root.ID)
+	send(carol, nil)
+
+	counts, err := db.GetUnreadCounts(alice)
+	if err != nil {
+		t.Fatalf("GetUnreadCounts: %v", err)
+	}
+	if counts[room.ID] != 1 {
+		t.Fatalf("expected 1 unread message, got %d", counts[room.ID])
+	}
+
+	count, err := db.GetUnreadCount(alice, room.ID)
+	if err != nil {
+		t.Fatalf("GetUnreadCount: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 unread message, got %d", count)
+	}
+
+	// Блокировка действует только в сторону alice
+	if count, _ := db.GetUnreadCount(carol, room.ID); count != 1 {
+		t.Fatalf("carol must still see bob's message, got %d", count)
+	}
+}
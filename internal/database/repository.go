@@ -0,0 +1,138 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+)
+
+// UserRepository хранилище пользователей
+type UserRepository interface {
+	SaveUser(user *models.User) error
+	UpdateUser(user *models.User) error
+	GetUser(id string) (*models.User, error)
+	FindUserByEmail(email string) (*models.User, error)
+	SearchUsersByUsername(query string) ([]models.User, error)
+	UpdateLastSeen(id string) error
+}
+
+// RoomRepository хранилище комнат
+type RoomRepository interface {
+	CreateRoom(room *models.Room) error
+	GetRoom(id string) (*models.Room, error)
+	GetUserRooms(userID string) ([]models.Room, error)
+	GetOrCreateDirectRoom(user1ID, user2ID uuid.UUID) (*models.Room, error)
+	UpdateRoom(room *models.Room) error
+	DeleteRoom(id string) error
+}
+
+// MembershipRepository участники комнат: членство, роли, баны и отметки о прочтении
+type MembershipRepository interface {
+	AddUserToRoom(userID, roomID string) error
+	RemoveUserFromRoom(userID, roomID string) error
+	IsRoomMember(userID, roomID string) (bool, error)
+	GetContactIDs(userID uuid.UUID) ([]uuid.UUID, error)
+
+	GetMemberRole(userID, roomID uuid.UUID) (string, error)
+	GetRoomMemberRoles(roomID uuid.UUID) (map[uuid.UUID]string, error)
+	SetMemberRole(userID, roomID uuid.UUID, role string) error
+
+	BanUser(ban *models.RoomBan) error
+	UnbanUser(userID, roomID uuid.UUID) error
+	IsBanned(userID, roomID uuid.UUID) (bool, error)
+	GetRoomBans(roomID uuid.UUID) ([]models.RoomBan, error)
+
+	MarkRoomRead(userID, roomID uuid.UUID, readAt time.Time) (bool, error)
+	GetUnreadCounts(userID uuid.UUID) (map[uuid.UUID]int64, error)
+	GetUnreadCount(userID, roomID uuid.UUID) (int64, error)
+	GetRoomReadStates(roomID uuid.UUID) ([]models.RoomMember, error)
+}
+
+// MessageRepository хранилище сообщений: треды, правки, модерация и поиск
+type MessageRepository interface {
+	SaveMessage(message *models.Message) error
+	SaveMessageWithAttachments(message *models.Message, attachments []models.MessageAttachment) error
+	GetAttachmentMessage(url string) (*models.Message, error)
+	GetMessage(id string) (*models.Message, error)
+	UpdateMessage(message *models.Message) error
+	EditMessage(message *models.Message, content string, editorID uuid.UUID) error
+	DeleteMessage(message *models.Message, actorID uuid.UUID) error
+
+	GetRoomMessages(roomID string, viewerID uuid.UUID, limit int, beforeID *uuid.UUID) ([]models.Message, error)
+	GetThreadReplies(threadID, viewerID uuid.UUID, limit int, beforeID *uuid.UUID) ([]models.Message, error)
+	GetUnreadMessages(userID string, roomID string, lastReadAt time.Time) ([]models.Message, error)
+	GetMessageRevisions(messageID uuid.UUID) ([]models.MessageRevision, error)
+	SearchMessages(params MessageSearchParams) ([]MessageSearchResult, error)
+
+	GetDeletedMessage(id string) (*models.Message, error)
+	RestoreMessage(message *models.Message, actorID uuid.UUID) error
+	GetMessageAuditLog(roomID uuid.UUID, limit int, before *time.Time) ([]models.MessageAuditLog, error)
+	PurgeDeletedMessages(deletedBefore time.Time) (int, []models.MessageAttachment, error)
+}
+
+// ReactionRepository хранилище реакций на сообщения
+type ReactionRepository interface {
+	AddReaction(reaction *models.MessageReaction) (bool, error)
+	RemoveReaction(messageID, userID uuid.UUID, emoji string) error
+	CountReactions(messageID uuid.UUID, emoji string) (int64, error)
+	GetReactionCounts(messageIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]ReactionCount, error)
+}
+
+// BlockRepository хранилище блокировок между пользователями
+type BlockRepository interface {
+	BlockUser(block *models.UserBlock) error
+	UnblockUser(blockerID, blockedID uuid.UUID) error
+	GetBlocks(blockerID uuid.UUID) ([]models.UserBlock, error)
+	GetBlockedIDs(userID uuid.UUID) ([]uuid.UUID, error)
+	GetBlockerIDs(userID uuid.UUID) ([]uuid.UUID, error)
+	IsBlockedEither(user1ID, user2ID uuid.UUID) (bool, error)
+}
+
+// InviteRepository приглашения в комнаты
+type InviteRepository interface {
+	CreateRoomInvite(invite *models.RoomInvite) error
+	GetRoomInvite(code string) (*models.RoomInvite, error)
+	GetRoomInvites(roomID uuid.UUID) ([]models.RoomInvite, error)
+	RevokeRoomInvite(roomID uuid.UUID, code string) error
+	AcceptRoomInvite(code string, userID uuid.UUID) (*models.RoomInvite, error)
+}
+
+// FriendRepository заявки в друзья и дружба
+type FriendRepository interface {
+	GetFriendship(user1ID, user2ID uuid.UUID) (*models.Friendship, error)
+	CreateFriendRequest(friendship *models.Friendship) error
+	AcceptFriendRequest(requesterID, addresseeID uuid.UUID) error
+	DeleteFriendRequest(requesterID, addresseeID uuid.UUID) error
+	RemoveFriend(user1ID, user2ID uuid.UUID) error
+	AreFriends(user1ID, user2ID uuid.UUID) (bool, error)
+	GetFriends(userID uuid.UUID) ([]models.User, error)
+	GetFriendRequests(userID uuid.UUID) ([]models.Friendship, error)
+}
+
+// PushRepository токены устройств и настройки уведомлений комнат
+type PushRepository interface {
+	SavePushToken(token *models.PushToken) error
+	DeletePushToken(userID uuid.UUID, token string) error
+	DeletePushTokensByID(ids []uuid.UUID) error
+	GetPushTokens(userIDs []uuid.UUID) ([]models.PushToken, error)
+
+	SetRoomMute(userID, roomID uuid.UUID, muted bool, until *time.Time) error
+	GetNotifiableMembers(roomID, senderID uuid.UUID) ([]uuid.UUID, error)
+}
+
+// Repository все хранилища приложения. Реализуется Database поверх
+// Postgres (Connect) или SQLite (OpenSQLite)
+type Repository interface {
+	UserRepository
+	RoomRepository
+	MembershipRepository
+	MessageRepository
+	ReactionRepository
+	BlockRepository
+	FriendRepository
+	InviteRepository
+	PushRepository
+}
+
+var _ Repository = (*Database)(nil)
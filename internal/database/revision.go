@@ -0,0 +1,50 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EditMessage сохраняет текущий текст сообщения в истории правок и заменяет его новым
+func (d *Database) EditMessage(message *models.Message, content string, editorID uuid.UUID) error {
+	now := time.Now()
+
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		revision := &models.MessageRevision{
+			MessageID: message.ID,
+			Content:   message.Content,
+			EditedBy:  editorID,
+			CreatedAt: now,
+		}
+		if err := tx.Omit(clause.Associations).Create(revision).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(message).Updates(map[string]interface{}{
+			"content":        content,
+			"edited_at":      now,
+			"revision_count": gorm.Expr("revision_count + 1"),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	message.Content = content
+	message.EditedAt = &now
+	message.RevisionCount++
+	return nil
+}
+
+// GetMessageRevisions возвращает прежние версии сообщения, старые первыми
+func (d *Database) GetMessageRevisions(messageID uuid.UUID) ([]models.MessageRevision, error) {
+	var revisions []models.MessageRevision
+	err := d.db.Where("message_id = ?", messageID).
+		Order("created_at ASC").
+		Find(&revisions).Error
+	return revisions, err
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+)
+
+// GetMemberRole возвращает роль участника в комнате; создатель комнаты всегда admin.
+// Если пользователь не состоит в комнате, возвращает gorm.ErrRecordNotFound.
+func (d *Database) GetMemberRole(userID, roomID uuid.UUID) (string, error) {
+	var roles []string
+	err := d.db.Table("room_members rm").
+		Select("CASE WHEN r.created_by = rm.user_id THEN ? ELSE COALESCE(rm.role, ?) END", models.RoleAdmin, models.RoleMember).
+		Joins("JOIN rooms r ON r.id = rm.room_id").
+		Where("rm.user_id = ? AND rm.room_id = ?", userID, roomID).
+		Limit(1).
+		Pluck("role", &roles).Error
+	if err != nil {
+		return "", err
+	}
+
+	if len(roles) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+	return roles[0], nil
+}
+
+// GetRoomMemberRoles возвращает роли всех участников комнаты
+func (d *Database) GetRoomMemberRoles(roomID uuid.UUID) (map[uuid.UUID]string, error) {
+	var members []models.RoomMember
+	if err := d.db.Where("room_id = ?", roomID).Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	roles := make(map[uuid.UUID]string, len(members))
+	for _, m := range members {
+		roles[m.UserID] = m.Role
+	}
+	return roles, nil
+}
+
+func (d *Database) SetMemberRole(userID, roomID uuid.UUID, role string) error {
+	result := d.db.Model(&models.RoomMember{}).
+		Where("user_id = ? AND room_id = ?", userID, roomID).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// BanUser исключает пользователя из комнаты и запрещает повторный вход
+func (d *Database) BanUser(ban *models.RoomBan) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND room_id = ?", ban.UserID, ban.RoomID).
+			Delete(&models.RoomMember{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Omit("Room", "User").Save(ban).Error
+	})
+}
+
+func (d *Database) UnbanUser(userID, roomID uuid.UUID) error {
+	result := d.db.Where("user_id = ? AND room_id = ?", userID, roomID).Delete(&models.RoomBan{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (d *Database) IsBanned(userID, roomID uuid.UUID) (bool, error) {
+	var count int64
+	err := d.db.Model(&models.RoomBan{}).
+		Where("user_id = ? AND room_id = ?", userID, roomID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (d *Database) GetRoomBans(roomID uuid.UUID) ([]models.RoomBan, error) {
+	var bans []models.RoomBan
+	err := d.db.Where("room_id = ?", roomID).
+		Preload("User").
+		Order("created_at DESC").
+		Find(&bans).Error
+	return bans, err
+}
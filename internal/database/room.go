@@ -46,7 +46,8 @@ func (d *Database) AddUserToRoom(userID, roomID string) error {
 		return err
 	}
 
-	return d.db.Model(&room).Association("Members").Append(&user)
+	// Триггер check_room_member_limit отклоняет вступление в заполненную комнату
+	return memberLimitError(d.db.Model(&room).Association("Members").Append(&user))
 }
 
 func (d *Database) RemoveUserFromRoom(userID, roomID string) error {
@@ -115,7 +116,8 @@ func (d *Database) UpdateRoom(room *models.Room) error {
 
 func (d *Database) DeleteRoom(id string) error {
 	return d.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Delete(&models.Message{}, "room_id = ?", id).Error; err != nil {
+		// Сообщения удаляются вместе с комнатой, а не оставляют заглушки
+		if err := tx.Unscoped().Delete(&models.Message{}, "room_id = ?", id).Error; err != nil {
 			return err
 		}
 
@@ -131,3 +133,12 @@ func (d *Database) DeleteRoom(id string) error {
 		return tx.Delete(&room).Error
 	})
 }
+
+// IsRoomMember проверяет, состоит ли пользователь в комнате
+func (d *Database) IsRoomMember(userID, roomID string) (bool, error) {
+	var count int64
+	err := d.db.Table("room_members").
+		Where("user_id = ? AND room_id = ?", userID, roomID).
+		Count(&count).Error
+	return count > 0, err
+}
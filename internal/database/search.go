@@ -0,0 +1,144 @@
+package database
+
+import (
+	"html"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+)
+
+// Конфигурация полнотекстового поиска, совпадает с search_vector в миграциях:
+// simple не стеммит, поэтому одинаково работает для сообщений на любом языке
+const searchConfig = "simple"
+
+// Текст сообщения экранируется для HTML до ts_headline, так что в фрагменте остается
+// только разметка StartSel/StopSel. Набор замен совпадает с html.EscapeString.
+const escapedContent = `replace(replace(replace(replace(replace(messages.content, ` +
+	`'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '''', '&#39;'), '"', '&#34;')`
+
+// Экранирование спецсимволов LIKE, чтобы запрос искался как обычная подстрока
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// MessageSearchCursor позиция в выдаче поиска: сортировка по created_at DESC, id DESC
+type MessageSearchCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// MessageSearchParams параметры поиска сообщений по комнатам пользователя
+type MessageSearchParams struct {
+	Query         string
+	UserID        uuid.UUID
+	RoomID        *uuid.UUID
+	AuthorID      *uuid.UUID
+	From          *time.Time
+	To            *time.Time
+	HasAttachment *bool
+	After         *MessageSearchCursor
+	Limit         int
+}
+
+// MessageSearchResult найденное сообщение с подсвеченным фрагментом
+type MessageSearchResult struct {
+	Message models.Message
+	Snippet string
+}
+
+// SearchMessages ищет сообщения в комнатах, где состоит пользователь.
+// Сообщения заблокированных им пользователей не возвращаются.
+func (d *Database) SearchMessages(params MessageSearchParams) ([]MessageSearchResult, error) {
+	query := d.db.Table("messages")
+	if d.isSQLite() {
+		// Без tsvector ищем подстроку без учета регистра, фрагментом служит весь текст
+		query = query.
+			Select("messages.id, messages.content AS snippet").
+			Where(`LOWER(messages.content) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(params.Query))+"%")
+	} else {
+		query = query.
+			Select("messages.id, ts_headline(?, "+escapedContent+", websearch_to_tsquery(?, ?), "+
+				"'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2') AS snippet",
+				searchConfig, searchConfig, params.Query).
+			Where("messages.search_vector @@ websearch_to_tsquery(?, ?)", searchConfig, params.Query)
+	}
+
+	query = query.
+		Where("messages.deleted_at IS NULL").
+		Where("messages.room_id IN (?)",
+			d.db.Table("room_members").Select("room_id").Where("user_id = ?", params.UserID)).
+		Where("messages.user_id NOT IN (?)",
+			d.db.Model(&models.UserBlock{}).Select("blocked_id").Where("blocker_id = ?", params.UserID))
+
+	if params.RoomID != nil {
+		query = query.Where("messages.room_id = ?", *params.RoomID)
+	}
+	if params.AuthorID != nil {
+		query = query.Where("messages.user_id = ?", *params.AuthorID)
+	}
+	if params.From != nil {
+		query = query.Where("messages.created_at >= ?", *params.From)
+	}
+	if params.To != nil {
+		query = query.Where("messages.created_at < ?", *params.To)
+	}
+	if params.HasAttachment != nil {
+		attachments := d.db.Model(&models.MessageAttachment{}).Select("1").
+			Where("message_attachments.message_id = messages.id")
+		if *params.HasAttachment {
+			query = query.Where("EXISTS (?)", attachments)
+		} else {
+			query = query.Where("NOT EXISTS (?)", attachments)
+		}
+	}
+	if params.After != nil {
+		query = query.Where("(messages.created_at, messages.id) < (?, ?)", params.After.CreatedAt, params.After.ID)
+	}
+
+	var rows []struct {
+		ID      uuid.UUID
+		Snippet string
+	}
+	err := query.
+		Order("messages.created_at DESC, messages.id DESC").
+		Limit(params.Limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Фрагмент отдается как HTML: на SQLite это весь текст, его тоже экранируем
+	if d.isSQLite() {
+		for i := range rows {
+			rows[i].Snippet = html.EscapeString(rows[i].Snippet)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+
+	var messages []models.Message
+	if err := d.db.Preload("User").Preload("Attachments").Where("id IN ?", ids).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]models.Message, len(messages))
+	for _, msg := range messages {
+		byID[msg.ID] = msg
+	}
+
+	// Сохраняем порядок выдачи поиска
+	results := make([]MessageSearchResult, 0, len(rows))
+	for _, row := range rows {
+		if msg, ok := byID[row.ID]; ok {
+			results = append(results, MessageSearchResult{Message: msg, Snippet: row.Snippet})
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+)
+
+func TestSearchMessagesEscapesLikeAndSnippet(t *testing.T) {
+	db := newTestDatabase(t)
+	alice := createTestUser(t, db, "alice")
+
+	room := &models.Room{Name: "general", Type: "group", CreatedBy: alice, CreatedAt: time.Now()}
+	if err := db.CreateRoom(room); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	if err := db.AddUserToRoom(alice.String(), room.ID.String()); err != nil {
+		t.Fatalf("AddUserToRoom: %v", err)
+	}
+
+	for _, content := range []string{"discount 50% today", "discount 500 today", "<script>alert(1)</script> file_name"} {
+		if err := db.SaveMessage(&models.Message{ID: uuid.New(), RoomID: room.ID, UserID: alice, Content: content, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+
+	// % и _ в запросе ищутся буквально, а не как шаблон
+	results, err := db.SearchMessages(MessageSearchParams{Query: "50%", UserID: alice, Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchMessages: %v", err)
+	}
+	if len(results) != 1 || results[0].Message.Content != "discount 50% today" {
+		t.Fatalf("expected only the literal 50%% match, got %d results", len(results))
+	}
+
+	results, err = db.SearchMessages(MessageSearchParams{Query: "file_", UserID: alice, Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchMessages: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if want := "&lt;script&gt;alert(1)&lt;/script&gt; file_name"; results[0].Snippet != want {
+		t.Fatalf("snippet must be HTML-escaped, got %q", results[0].Snippet)
+	}
+}
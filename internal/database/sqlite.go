@@ -0,0 +1,67 @@
+package database
+
+import (
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Ограничения из миграций, которые AutoMigrate не выводит из моделей
+var sqliteConstraints = []string{
+	// Встречные заявки в друзья не создают вторую запись для той же пары (0003_friends)
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_friendships_pair
+		ON friendships (min(requester_id, addressee_id), max(requester_id, addressee_id))`,
+}
+
+// OpenSQLite открывает базу SQLite для тестов и локального запуска без внешних сервисов:
+// dsn "file::memory:" создает пустую базу в памяти.
+//
+// Миграции написаны для Postgres, поэтому схема SQLite строится по моделям через AutoMigrate
+// и дополняется sqliteConstraints. От схемы миграций она отличается:
+//   - нет messages.search_vector и GIN индекса: поиск сообщений работает по подстроке без ранжирования;
+//   - нет триггера check_room_member_limit: лимит участников комнаты на SQLite не проверяется;
+//   - типы колонок (UUID, TIMESTAMPTZ, BIGINT) сводятся к TEXT, DATETIME и INTEGER.
+//
+// Поэтому код, зависящий от триггеров и поиска, проверяется только на Postgres.
+func OpenSQLite(dsn string) (*Database, error) {
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// Одно соединение: база в памяти живет внутри соединения,
+	// а SQLite все равно сериализует запись
+	sqlDB.SetMaxOpenConns(1)
+
+	// Внешние ключи в SQLite выключены по умолчанию
+	if err := db.Exec("PRAGMA foreign_keys = ON").Error; err != nil {
+		return nil, err
+	}
+
+	if err := setup(db); err != nil {
+		return nil, err
+	}
+
+	err = db.AutoMigrate(&models.User{}, &models.Room{}, &models.Message{}, &models.MessageReaction{}, &models.MessageAttachment{}, &models.RoomBan{}, &models.UserBlock{}, &models.MessageAuditLog{}, &models.MessageRevision{}, &models.PushToken{}, &models.Friendship{}, &models.RoomInvite{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range sqliteConstraints {
+		if err := db.Exec(stmt).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &Database{db: db}, nil
+}
+
+// isSQLite сообщает, что база работает на SQLite, где нет полнотекстового поиска Postgres
+func (d *Database) isSQLite() bool {
+	return d.db.Dialector.Name() == "sqlite"
+}
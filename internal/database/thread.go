@@ -0,0 +1,40 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+)
+
+// GetThreadReplies получает ответы треда с пагинацией, старые первыми
+func (d *Database) GetThreadReplies(threadID, viewerID uuid.UUID, limit int, beforeID *uuid.UUID) ([]models.Message, error) {
+	query := d.db.Unscoped().Where("thread_id = ?", threadID)
+	return d.getMessagesPage(query, viewerID, limit, beforeID)
+}
+
+// incrementThreadReplies обновляет счетчик ответов и время последнего ответа корня треда
+func incrementThreadReplies(tx *gorm.DB, message *models.Message) error {
+	if message.ThreadID == nil {
+		return nil
+	}
+
+	return tx.Model(&models.Message{}).
+		Unscoped().
+		Where("id = ?", *message.ThreadID).
+		Updates(map[string]interface{}{
+			"reply_count":   gorm.Expr("reply_count + 1"),
+			"last_reply_at": message.CreatedAt,
+		}).Error
+}
+
+// changeThreadReplies меняет счетчик ответов треда при удалении и восстановлении ответа
+func changeThreadReplies(tx *gorm.DB, message *models.Message, delta int) error {
+	if message.ThreadID == nil {
+		return nil
+	}
+
+	return tx.Model(&models.Message{}).
+		Unscoped().
+		Where("id = ?", *message.ThreadID).
+		Update("reply_count", gorm.Expr("CASE WHEN reply_count + ? > 0 THEN reply_count + ? ELSE 0 END", delta, delta)).Error
+}
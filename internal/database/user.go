@@ -2,6 +2,7 @@ package database
 
 import (
 	"github.com/thereayou/discord-lite/internal/models"
+	"strings"
 	"time"
 )
 
@@ -34,7 +35,7 @@ func (d *Database) FindUserByEmail(email string) (*models.User, error) {
 
 func (d *Database) SearchUsersByUsername(query string) ([]models.User, error) {
 	var users []models.User
-	err := d.db.Where("username ILIKE ?", "%"+query+"%").
+	err := d.db.Where("LOWER(username) LIKE ?", "%"+strings.ToLower(query)+"%").
 		Limit(20).
 		Find(&users).Error
 	return users, err
@@ -0,0 +1,93 @@
+package dto
+
+import "github.com/thereayou/discord-lite/internal/models"
+
+// Максимальная длина цитаты в превью ответа, в символах
+const maxPreviewLength = 200
+
+// NewMessageResponse формирует ответ для сообщения от имени author
+func NewMessageResponse(msg *models.Message, author *models.User) MessageResponse {
+	// Удаленное сообщение отдается заглушкой без содержимого
+	if msg.DeletedAt.Valid {
+		return MessageResponse{
+			ID:          msg.ID,
+			RoomID:      msg.RoomID,
+			UserID:      msg.UserID,
+			Type:        msg.Type,
+			CreatedAt:   msg.CreatedAt,
+			Deleted:     true,
+			DeletedAt:   &msg.DeletedAt.Time,
+			ThreadID:    msg.ThreadID,
+			ReplyCount:  msg.ReplyCount,
+			LastReplyAt: msg.LastReplyAt,
+		}
+	}
+
+	return MessageResponse{
+		ID:        msg.ID,
+		RoomID:    msg.RoomID,
+		UserID:    msg.UserID,
+		Content:   msg.Content,
+		Type:      msg.Type,
+		CreatedAt: msg.CreatedAt,
+		EditedAt:  msg.EditedAt,
+		User: UserInfo{
+			ID:        author.ID,
+			Username:  author.Username,
+			AvatarURL: author.AvatarURL,
+		},
+		ReplyTo:       NewMessagePreview(msg.ReplyTo),
+		ThreadID:      msg.ThreadID,
+		ReplyCount:    msg.ReplyCount,
+		LastReplyAt:   msg.LastReplyAt,
+		RevisionCount: msg.RevisionCount,
+		Attachments:   NewAttachmentResponses(msg.Attachments),
+	}
+}
+
+// NewMessagePreview формирует цитату сообщения; у удаленного оригинала текст не раскрывается
+func NewMessagePreview(msg *models.Message) *MessagePreview {
+	if msg == nil {
+		return nil
+	}
+
+	preview := &MessagePreview{
+		ID:        msg.ID,
+		UserID:    msg.UserID,
+		Username:  msg.User.Username,
+		CreatedAt: msg.CreatedAt,
+	}
+
+	if msg.DeletedAt.Valid {
+		preview.Deleted = true
+		return preview
+	}
+
+	content := []rune(msg.Content)
+	if len(content) > maxPreviewLength {
+		content = append(content[:maxPreviewLength], '…')
+	}
+	preview.Content = string(content)
+
+	return preview
+}
+
+// NewAttachmentResponses преобразует вложения в формат ответа
+func NewAttachmentResponses(attachments []models.MessageAttachment) []AttachmentResponse {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	responses := make([]AttachmentResponse, len(attachments))
+	for i, a := range attachments {
+		responses[i] = AttachmentResponse{
+			ID:           a.ID,
+			FileName:     a.FileName,
+			FileSize:     a.FileSize,
+			FileType:     a.FileType,
+			URL:          a.FileURL,
+			ThumbnailURL: a.ThumbnailURL,
+		}
+	}
+	return responses
+}
@@ -0,0 +1,121 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// MessagePayload структура для входящих сообщений
+type MessagePayload struct {
+	Content   string     `json:"content"`
+	Type      string     `json:"type,omitempty"` // text, image, file
+	ReplyToID *uuid.UUID `json:"reply_to_id,omitempty"`
+	ThreadID  *uuid.UUID `json:"thread_id,omitempty"`
+}
+
+// MessageResponse структура для исходящих сообщений
+type MessageResponse struct {
+	ID            uuid.UUID            `json:"id"`
+	RoomID        uuid.UUID            `json:"room_id"`
+	UserID        uuid.UUID            `json:"user_id"`
+	Content       string               `json:"content"`
+	Type          string               `json:"type"`
+	CreatedAt     time.Time            `json:"created_at"`
+	EditedAt      *time.Time           `json:"edited_at,omitempty"`
+	RevisionCount int                  `json:"revision_count,omitempty"`
+	Deleted       bool                 `json:"deleted,omitempty"`
+	DeletedAt     *time.Time           `json:"deleted_at,omitempty"`
+	User          UserInfo             `json:"user"`
+	ReplyTo       *MessagePreview      `json:"reply_to,omitempty"`
+	ThreadID      *uuid.UUID           `json:"thread_id,omitempty"`
+	ReplyCount    int                  `json:"reply_count,omitempty"`
+	LastReplyAt   *time.Time           `json:"last_reply_at,omitempty"`
+	Reactions     []ReactionSummary    `json:"reactions,omitempty"`
+	Attachments   []AttachmentResponse `json:"attachments,omitempty"`
+}
+
+// SearchResultResponse найденное сообщение с подсвеченным фрагментом
+type SearchResultResponse struct {
+	MessageResponse
+	Snippet string `json:"snippet"`
+}
+
+// MessagePreview краткая цитата сообщения, на которое отвечают
+type MessagePreview struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	Deleted   bool      `json:"deleted,omitempty"`
+}
+
+// ThreadUpdate событие изменения треда для всех в комнате
+type ThreadUpdate struct {
+	ThreadID    uuid.UUID  `json:"thread_id"`
+	ReplyCount  int        `json:"reply_count"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+}
+
+// AttachmentResponse структура вложения в исходящем сообщении
+type AttachmentResponse struct {
+	ID           uuid.UUID `json:"id"`
+	FileName     string    `json:"file_name"`
+	FileSize     int64     `json:"file_size"`
+	FileType     string    `json:"file_type"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+}
+
+// ReactionSummary количество реакций одним emoji на сообщение
+type ReactionSummary struct {
+	Emoji       string `json:"emoji"`
+	Count       int    `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
+}
+
+// ReactionRequest структура для добавления реакции
+type ReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required,max=10"`
+}
+
+// ReactionEvent событие изменения реакций для рассылки в комнату
+type ReactionEvent struct {
+	MessageID uuid.UUID `json:"message_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+	Count     int64     `json:"count"`
+}
+
+type UserInfo struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url,omitempty"`
+}
+
+// ReadRequest отметка прочтения комнаты до сообщения включительно
+type ReadRequest struct {
+	MessageID uuid.UUID `json:"message_id" binding:"required"`
+}
+
+// ReadReceipt событие прочтения для индикаторов "просмотрено"
+type ReadReceipt struct {
+	RoomID    uuid.UUID `json:"room_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	MessageID uuid.UUID `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+// UnreadCount количество непрочитанных сообщений в комнате
+type UnreadCount struct {
+	RoomID      uuid.UUID `json:"room_id"`
+	UnreadCount int64     `json:"unread_count"`
+}
+
+// MessageRevision прежняя версия сообщения. Content виден только автору и администраторам комнаты
+type MessageRevision struct {
+	Version    int       `json:"version"`
+	EditedBy   uuid.UUID `json:"edited_by"`
+	ReplacedAt time.Time `json:"replaced_at"`
+	Content    *string   `json:"content,omitempty"`
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/services"
+	"github.com/thereayou/discord-lite/pkg/storage"
+	"gorm.io/gorm"
+)
+
+const (
+	// Максимум файлов в одном сообщении
+	maxAttachmentsPerMessage = 10
+
+	// Размер стороны превью в пикселях
+	thumbnailSize = 320
+)
+
+// Разрешенные типы файлов и расширения, под которыми они хранятся. Тип определяется
+// по содержимому, расширение из имени клиента не используется.
+var attachmentExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"text/plain":      ".txt",
+	"audio/mpeg":      ".mp3",
+	"video/mp4":       ".mp4",
+}
+
+// Тип содержимого по расширению сохраненного файла, для отдачи вложений
+var attachmentContentTypes = func() map[string]string {
+	types := make(map[string]string, len(attachmentExtensions))
+	for contentType, ext := range attachmentExtensions {
+		types[ext] = contentType
+	}
+	return types
+}()
+
+var unsafeFileNameChars = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
+type AttachmentHandler struct {
+	db       database.Repository
+	messages *services.MessageService
+	storage  storage.Storage
+	maxSize  int64
+}
+
+func NewAttachmentHandler(db database.Repository, messages *services.MessageService, store storage.Storage, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{db: db, messages: messages, storage: store, maxSize: maxSize}
+}
+
+// UploadAttachments создает сообщение с вложениями из multipart формы (поля files и content)
+func (h *AttachmentHandler) UploadAttachments(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+	roomIDStr := c.Param("id")
+
+	roomID, err := uuid.Parse(roomIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room id"})
+		return
+	}
+
+	isMember, err := h.db.IsRoomMember(userID.String(), roomIDStr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check membership"})
+		return
+	}
+
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are not a member of this room"})
+		return
+	}
+
+	// Ограничиваем тело запроса, чтобы не читать лишнее
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize*maxAttachmentsPerMessage+1<<20)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "invalid or too large multipart form"})
+		return
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one file is required"})
+		return
+	}
+
+	if len(files) > maxAttachmentsPerMessage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many files"})
+		return
+	}
+
+	for _, fh := range files {
+		if fh.Size > h.maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large: " + fh.Filename})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	attachments := make([]models.MessageAttachment, 0, len(files))
+	storedKeys := make([]string, 0, len(files)*2)
+	allImages := true
+
+	for _, fh := range files {
+		attachment, keys, status, err := h.storeFile(ctx, roomID, fh)
+		storedKeys = append(storedKeys, keys...)
+		if err != nil {
+			h.cleanup(storedKeys)
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !strings.HasPrefix(attachment.FileType, "image/") {
+			allImages = false
+		}
+		attachments = append(attachments, *attachment)
+	}
+
+	msgType := "file"
+	if allImages {
+		msgType = "image"
+	}
+
+	message, err := h.messages.Send(services.SendMessageInput{
+		RoomID:      roomID,
+		UserID:      userID,
+		Content:     c.PostForm("content"),
+		Type:        msgType,
+		Attachments: attachments,
+	})
+	if err != nil {
+		h.cleanup(storedKeys)
+		respondMessageError(c, err, "failed to save message")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewMessageResponse(message, &message.User))
+}
+
+// storeFile проверяет файл, сохраняет его и превью (для изображений) в хранилище.
+// Возвращает сохраненные ключи, чтобы их можно было удалить при ошибке.
+func (h *AttachmentHandler) storeFile(ctx context.Context, roomID uuid.UUID, fh *multipart.FileHeader) (*models.MessageAttachment, []string, int, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, h.maxSize+1))
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+
+	if int64(len(data)) > h.maxSize {
+		return nil, nil, http.StatusRequestEntityTooLarge, fmt.Errorf("file is too large: %s", fh.Filename)
+	}
+
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	ext, ok := attachmentExtensions[contentType]
+	if !ok {
+		return nil, nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported file type: %s", contentType)
+	}
+
+	fileName := sanitizeFileName(fh.Filename)
+	storedName := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ext
+	prefix := "attachments/" + roomID.String() + "/" + uuid.New().String()
+	key := prefix + "/" + storedName
+
+	fileURL, err := h.storage.Put(ctx, key, bytes.NewReader(data), contentType)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+	keys := []string{key}
+
+	attachment := &models.MessageAttachment{
+		FileName:  fileName,
+		FileSize:  int64(len(data)),
+		FileType:  contentType,
+		FileURL:   fileURL,
+		CreatedAt: time.Now(),
+	}
+
+	if strings.HasPrefix(contentType, "image/") {
+		// Превью не обязательно: без него клиент покажет оригинал
+		thumb, err := storage.MakeThumbnail(data, thumbnailSize)
+		if err != nil {
+			log.Printf("Failed to make thumbnail for %s: %v", key, err)
+			return attachment, keys, http.StatusOK, nil
+		}
+
+		thumbKey := prefix + "/thumbs/" + storedName + ".jpg"
+		thumbURL, err := h.storage.Put(ctx, thumbKey, bytes.NewReader(thumb), "image/jpeg")
+		if err != nil {
+			return nil, keys, http.StatusInternalServerError, err
+		}
+		keys = append(keys, thumbKey)
+		attachment.ThumbnailURL = thumbURL
+	}
+
+	return attachment, keys, http.StatusOK, nil
+}
+
+// ServeAttachment отдает сохраненный файл участнику комнаты, к которой он относится.
+// Все, кроме изображений, отдается как загрузка, чтобы браузер не исполнял содержимое.
+func (h *AttachmentHandler) ServeAttachment(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	// Ключ вида attachments/<room_id>/<uuid>/<имя>
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) != 3 || parts[0] != "attachments" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+
+	if _, err := uuid.Parse(parts[1]); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+
+	// Файлы удаленных сообщений не отдаются, даже пока их не удалила очистка
+	message, err := h.db.GetAttachmentMessage(h.storage.URL(key))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load attachment"})
+		return
+	}
+
+	isMember, err := h.db.IsRoomMember(userID.String(), message.RoomID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check membership"})
+		return
+	}
+
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are not a member of this room"})
+		return
+	}
+
+	f, err := h.storage.Open(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+		return
+	}
+	defer f.Close()
+
+	name := path.Base(key)
+	contentType, ok := attachmentContentTypes[path.Ext(name)]
+	if !ok {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age=86400")
+	if strings.HasPrefix(contentType, "image/") {
+		c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": name}))
+	} else {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+
+	http.ServeContent(c.Writer, c.Request, name, time.Time{}, f)
+}
+
+func (h *AttachmentHandler) cleanup(keys []string) {
+	for _, key := range keys {
+		if err := h.storage.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to delete stored file %s: %v", key, err)
+		}
+	}
+}
+
+// sanitizeFileName оставляет только безопасные символы имени файла
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = unsafeFileNameChars.ReplaceAllString(name, "_")
+	name = strings.Trim(name, "._")
+	if name == "" {
+		name = "file"
+	}
+	if runes := []rune(name); len(runes) > 200 {
+		name = string(runes[len(runes)-200:])
+	}
+	return name
+}
@@ -3,27 +3,41 @@ package handlers
 import (
 	"context"
 	"gorm.io/gorm"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/thereayou/discord-lite/internal/database"
-	"github.com/thereayou/discord-lite/internal/handlers/dto"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/middleware"
 	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/websocket"
 	"github.com/thereayou/discord-lite/pkg/auth"
+	"github.com/thereayou/discord-lite/pkg/ratelimit"
 )
 
 type AuthHandler struct {
-	db         *database.Database
+	db         database.UserRepository
 	jwtManager *auth.JWTManager
+	sessions   *auth.SessionManager
 	redis      *redis.Client
+
+	// Закрывает WebSocket соединения отозванных сессий
+	hub *websocket.Hub
+
+	// Временная блокировка входа по паре email и IP после серии неудачных попыток
+	lockout *ratelimit.Lockout
 }
 
-func NewAuthHandler(db *database.Database, jwtMgr *auth.JWTManager, rdb *redis.Client) *AuthHandler {
-	return &AuthHandler{db: db, jwtManager: jwtMgr, redis: rdb}
+func NewAuthHandler(db database.UserRepository, jwtMgr *auth.JWTManager, sessions *auth.SessionManager, rdb *redis.Client, hub *websocket.Hub, lockout *ratelimit.Lockout) *AuthHandler {
+	return &AuthHandler{db: db, jwtManager: jwtMgr, sessions: sessions, redis: rdb, hub: hub, lockout: lockout}
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
@@ -54,7 +68,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "user registered"})
 }
 
-// Login выдаёт JWT и обновляет last_seen
+// Login открывает сессию, выдаёт access и refresh токены и обновляет last_seen
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req dto.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -62,17 +76,33 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Ключ включает IP: чужие неудачные попытки не должны блокировать вход владельцу
+	// аккаунта, перебор с одного адреса по многим аккаунтам ограничивает лимит по IP
+	lockKey := "login:" + strings.ToLower(req.Email) + ":" + c.ClientIP()
+	locked, err := h.lockout.Locked(c.Request.Context(), lockKey)
+	if err != nil {
+		log.Printf("Login lockout check error: %v", err)
+	}
+	if locked > 0 {
+		h.respondLocked(c, locked)
+		return
+	}
+
 	user, err := h.db.FindUserByEmail(req.Email)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		h.loginFailed(c, lockKey)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		h.loginFailed(c, lockKey)
 		return
 	}
 
+	if err := h.lockout.Reset(c.Request.Context(), lockKey); err != nil {
+		log.Printf("Login lockout reset error: %v", err)
+	}
+
 	if err := h.db.UpdateLastSeen(user.ID.String()); err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
@@ -83,13 +113,70 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.jwtManager.Generate(user.ID.String())
+	session, refreshToken, err := h.sessions.Create(c.Request.Context(), user.ID.String(), c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create session"})
+		return
+	}
+
+	h.respondWithTokens(c, user.ID.String(), session.ID, refreshToken)
+}
+
+// loginFailed засчитывает неудачную попытку входа; после серии неудач вход блокируется
+func (h *AuthHandler) loginFailed(c *gin.Context, lockKey string) {
+	locked, err := h.lockout.Fail(c.Request.Context(), lockKey)
+	if err != nil {
+		log.Printf("Login lockout error: %v", err)
+	}
+	if locked > 0 {
+		h.respondLocked(c, locked)
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+}
+
+func (h *AuthHandler) respondLocked(c *gin.Context, locked time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(middleware.Seconds(locked)))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, try again later"})
+}
+
+// Refresh обменивает refresh токен на новую пару токенов
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req dto.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, refreshToken, err := h.sessions.Rotate(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		switch err {
+		case auth.ErrRefreshTokenReused:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected, session revoked"})
+		case auth.ErrInvalidRefreshToken:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not refresh token"})
+		}
+		return
+	}
+
+	h.respondWithTokens(c, session.UserID, session.ID, refreshToken)
+}
+
+func (h *AuthHandler) respondWithTokens(c *gin.Context, userID, sessionID, refreshToken string) {
+	accessToken, err := h.jwtManager.Generate(userID, sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, dto.TokenResponse{
+		AccessToken:     accessToken,
+		RefreshToken:    refreshToken,
+		AccessExpiresAt: time.Now().Add(h.jwtManager.TokenDuration()),
+	})
 }
 
 // Logout ставит токен в черный список в Redis до истечения
@@ -109,5 +196,72 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	ttl := time.Until(exp)
 	h.redis.Set(context.Background(), "blacklist:"+rawToken, 1, ttl)
 
+	// Отзываем сессию, чтобы её refresh токен больше не работал
+	if sessionID := c.GetString(middleware.SessionIDKey); sessionID != "" {
+		h.sessions.Revoke(c.Request.Context(), sessionID)
+		h.hub.CloseSession(c.MustGet(middleware.UserIDKey).(uuid.UUID), sessionID)
+	}
+
 	c.Status(http.StatusOK)
 }
+
+// ListSessions возвращает активные сессии текущего пользователя
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+	currentID := c.GetString(middleware.SessionIDKey)
+
+	sessions, err := h.sessions.List(c.Request.Context(), userID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get sessions"})
+		return
+	}
+
+	result := make([]gin.H, len(sessions))
+	for i, session := range sessions {
+		result[i] = gin.H{
+			"id":           session.ID,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"created_at":   session.CreatedAt,
+			"last_used_at": session.LastUsedAt,
+			"current":      session.ID == currentID,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
+
+// RevokeSession завершает сессию пользователя на другом устройстве
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+	sessionID := c.Param("id")
+
+	session, err := h.sessions.Get(c.Request.Context(), sessionID)
+	if err != nil || session.UserID != userID.String() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	if err := h.sessions.Revoke(c.Request.Context(), sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+	h.hub.CloseSession(userID, sessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// RevokeOtherSessions завершает все сессии пользователя, кроме текущей
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	currentID := c.GetString(middleware.SessionIDKey)
+
+	if err := h.sessions.RevokeOthers(c.Request.Context(), userID.String(), currentID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+	h.hub.CloseOtherSessions(userID, currentID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "other sessions revoked"})
+}
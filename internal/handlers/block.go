@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/websocket"
+)
+
+type BlockHandler struct {
+	db  database.Repository
+	hub *websocket.Hub
+}
+
+func NewBlockHandler(db database.Repository, hub *websocket.Hub) *BlockHandler {
+	return &BlockHandler{db: db, hub: hub}
+}
+
+// BlockUser блокирует пользователя
+func (h *BlockHandler) BlockUser(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if targetID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot block yourself"})
+		return
+	}
+
+	if _, err := h.db.GetUser(targetID.String()); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	block := &models.UserBlock{
+		BlockerID: userID,
+		BlockedID: targetID,
+		CreatedAt: time.Now(),
+	}
+
+	if err := h.db.BlockUser(block); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to block user"})
+		return
+	}
+
+	h.hub.UpdateBlock(userID, targetID, true)
+
+	// Блокировка разрывает дружбу
+	h.hub.InvalidateContacts(userID, targetID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "user blocked"})
+}
+
+// UnblockUser снимает блокировку
+func (h *BlockHandler) UnblockUser(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.db.UnblockUser(userID, targetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unblock user"})
+		return
+	}
+
+	h.hub.UpdateBlock(userID, targetID, false)
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unblocked"})
+}
+
+// GetBlocks возвращает список заблокированных текущим пользователем
+func (h *BlockHandler) GetBlocks(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	blocks, err := h.db.GetBlocks(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get blocked users"})
+		return
+	}
+
+	result := make([]gin.H, len(blocks))
+	for i, block := range blocks {
+		result[i] = gin.H{
+			"id":         block.Blocked.ID,
+			"username":   block.Blocked.Username,
+			"avatar_url": block.Blocked.AvatarURL,
+			"blocked_at": block.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": result})
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/websocket"
+	"gorm.io/gorm"
+)
+
+type FriendHandler struct {
+	db  database.Repository
+	hub *websocket.Hub
+}
+
+func NewFriendHandler(db database.Repository, hub *websocket.Hub) *FriendHandler {
+	return &FriendHandler{db: db, hub: hub}
+}
+
+// GetFriends возвращает друзей текущего пользователя с их статусами
+func (h *FriendHandler) GetFriends(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	friends, err := h.db.GetFriends(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get friends"})
+		return
+	}
+
+	ids := make([]uuid.UUID, len(friends))
+	for i, friend := range friends {
+		ids[i] = friend.ID
+	}
+	presence := h.hub.GetPresence(userID, ids)
+
+	result := make([]gin.H, len(friends))
+	for i, friend := range friends {
+		result[i] = gin.H{
+			"id":            friend.ID,
+			"username":      friend.Username,
+			"avatar_url":    friend.AvatarURL,
+			"online":        presence[i].Status != websocket.StatusOffline,
+			"status":        presence[i].Status,
+			"custom_status": presence[i].CustomStatus,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"friends": result})
+}
+
+// GetFriendRequests возвращает входящие и исходящие заявки в друзья
+func (h *FriendHandler) GetFriendRequests(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	requests, err := h.db.GetFriendRequests(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get friend requests"})
+		return
+	}
+
+	incoming := make([]gin.H, 0)
+	outgoing := make([]gin.H, 0)
+	for _, request := range requests {
+		other := request.OtherUser(userID)
+		item := gin.H{
+			"id":         other.ID,
+			"username":   other.Username,
+			"avatar_url": other.AvatarURL,
+			"created_at": request.CreatedAt,
+		}
+
+		if request.AddresseeID == userID {
+			incoming = append(incoming, item)
+		} else {
+			outgoing = append(outgoing, item)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"incoming": incoming, "outgoing": outgoing})
+}
+
+// SendFriendRequest отправляет заявку в друзья. Если встречная заявка уже есть,
+// она принимается и пользователи сразу становятся друзьями.
+func (h *FriendHandler) SendFriendRequest(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	targetID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if targetID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot send friend request to yourself"})
+		return
+	}
+
+	if _, err := h.db.GetUser(targetID.String()); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	blocked, err := h.db.IsBlockedEither(userID, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send friend request"})
+		return
+	}
+
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot send friend request to this user"})
+		return
+	}
+
+	existing, err := h.db.GetFriendship(userID, targetID)
+	switch {
+	case err == nil && existing.Status == models.FriendshipAccepted:
+		c.JSON(http.StatusConflict, gin.H{"error": "already friends"})
+		return
+	case err == nil && existing.RequesterID == userID:
+		c.JSON(http.StatusConflict, gin.H{"error": "friend request already sent"})
+		return
+	case err == nil:
+		// Встречная заявка: принимаем ее
+		if err := h.db.AcceptFriendRequest(targetID, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to accept friend request"})
+			return
+		}
+		h.friendsChanged(websocket.TypeFriendAdded, userID, targetID)
+
+		c.JSON(http.StatusOK, gin.H{"status": models.FriendshipAccepted})
+		return
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send friend request"})
+		return
+	}
+
+	friendship := &models.Friendship{
+		RequesterID: userID,
+		AddresseeID: targetID,
+		Status:      models.FriendshipPending,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := h.db.CreateFriendRequest(friendship); err != nil {
+		// Встречная заявка или дружба появилась после проверки выше
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "friend request already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send friend request"})
+		return
+	}
+
+	h.notifyUser(websocket.TypeFriendRequest, userID, targetID, userID)
+
+	c.JSON(http.StatusCreated, gin.H{"status": models.FriendshipPending})
+}
+
+// AcceptFriendRequest принимает входящую заявку от пользователя :user_id
+func (h *FriendHandler) AcceptFriendRequest(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	requesterID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.db.AcceptFriendRequest(requesterID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "friend request not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to accept friend request"})
+		return
+	}
+
+	h.friendsChanged(websocket.TypeFriendAdded, userID, requesterID)
+
+	c.JSON(http.StatusOK, gin.H{"status": models.FriendshipAccepted})
+}
+
+// DeclineFriendRequest отклоняет входящую заявку. Отправитель об этом не узнает.
+func (h *FriendHandler) DeclineFriendRequest(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	requesterID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.db.DeleteFriendRequest(requesterID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "friend request not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decline friend request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "friend request declined"})
+}
+
+// CancelFriendRequest отменяет исходящую заявку пользователю :user_id
+func (h *FriendHandler) CancelFriendRequest(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.db.DeleteFriendRequest(userID, targetID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "friend request not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel friend request"})
+		return
+	}
+
+	h.notifyUser(websocket.TypeFriendRequestCanceled, userID, targetID, userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "friend request canceled"})
+}
+
+// RemoveFriend удаляет пользователя :user_id из друзей
+func (h *FriendHandler) RemoveFriend(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	friendID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.db.RemoveFriend(userID, friendID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user is not your friend"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove friend"})
+		return
+	}
+
+	h.friendsChanged(websocket.TypeFriendRemoved, userID, friendID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "friend removed"})
+}
+
+// friendsChanged сбрасывает контакты пары и уведомляет обоих: каждому приходит другой пользователь
+func (h *FriendHandler) friendsChanged(msgType websocket.MessageType, actorID, otherID uuid.UUID) {
+	h.hub.InvalidateContacts(actorID, otherID)
+
+	h.notifyUser(msgType, actorID, otherID, actorID)
+	h.notifyUser(msgType, actorID, actorID, otherID)
+}
+
+// notifyUser отправляет recipientID событие о пользователе subjectID
+func (h *FriendHandler) notifyUser(msgType websocket.MessageType, actorID, recipientID, subjectID uuid.UUID) {
+	subject, err := h.db.GetUser(subjectID.String())
+	if err != nil {
+		return
+	}
+
+	wsMsg := websocket.Message{
+		Type:      msgType,
+		UserID:    actorID,
+		Timestamp: time.Now(),
+	}
+
+	eventData, _ := json.Marshal(gin.H{
+		"user": gin.H{
+			"id":         subject.ID,
+			"username":   subject.Username,
+			"avatar_url": subject.AvatarURL,
+		},
+	})
+	wsMsg.Data = eventData
+
+	msgData, _ := json.Marshal(wsMsg)
+	h.hub.SendToUser(recipientID, msgData)
+}
@@ -0,0 +1,526 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/services"
+	"github.com/thereayou/discord-lite/internal/websocket"
+	"github.com/thereayou/discord-lite/pkg/ratelimit"
+	"github.com/thereayou/discord-lite/pkg/storage"
+)
+
+// Заголовок, которым тесты представляются пользователем вместо JWT
+const testUserHeader = "X-Test-User"
+
+const (
+	expectTimeout  = 2 * time.Second
+	silenceTimeout = 200 * time.Millisecond
+)
+
+// testServer handlers поверх SQLite в памяти и настоящего hub
+type testServer struct {
+	db  *database.Database
+	hub *websocket.Hub
+	srv *httptest.Server
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := database.OpenSQLite("file::memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+
+	hub := websocket.NewHub()
+	hub.SetMembershipChecker(db)
+	hub.SetContactSource(db)
+	hub.SetBlockSource(db)
+	go hub.Run()
+
+	// Redis в тестах нет: лимитер получает ошибку соединения и пропускает запросы
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+
+	store, err := storage.NewLocalStorage(t.TempDir(), "/api/v1/files")
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	messages := services.NewMessageService(db, hub)
+	messageH := NewHTTPMessageHandler(db, messages)
+	reactionH := NewReactionHandler(db, hub)
+	attachmentH := NewAttachmentHandler(db, messages, store, 1<<20)
+	roomH := NewRoomHandler(db, hub)
+	wsH := NewWebSocketHandler(db, hub, NewMessageHandler(db, hub, messages, ratelimit.NewLimiter(rdb)))
+
+	r := gin.New()
+	api := r.Group("/api/v1")
+	api.Use(testAuth)
+	{
+		api.POST("/rooms", roomH.CreateRoom)
+		api.GET("/rooms/:id/messages", messageH.GetRoomMessages)
+		api.POST("/rooms/:id/messages", messageH.SendMessage)
+		api.PUT("/messages/:id", messageH.UpdateMessage)
+		api.DELETE("/messages/:id", messageH.DeleteMessage)
+		api.POST("/rooms/:id/attachments", attachmentH.UploadAttachments)
+		api.GET("/files/*key", attachmentH.ServeAttachment)
+		api.POST("/messages/:id/reactions", reactionH.AddReaction)
+		api.DELETE("/messages/:id/reactions/:emoji", reactionH.RemoveReaction)
+	}
+	r.GET("/ws", testAuth, wsH.HandleWebSocket)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), expectTimeout)
+		defer cancel()
+		hub.Shutdown(ctx)
+		srv.Close()
+	})
+
+	return &testServer{db: db, hub: hub, srv: srv}
+}
+
+func testAuth(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetHeader(testUserHeader))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	c.Set(middleware.UserIDKey, userID)
+	c.Next()
+}
+
+func (ts *testServer) createUser(t *testing.T, name string) uuid.UUID {
+	t.Helper()
+
+	user := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	if err := ts.db.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+	return user.ID
+}
+
+func (ts *testServer) createRoom(t *testing.T, members ...uuid.UUID) uuid.UUID {
+	t.Helper()
+
+	room := &models.Room{Name: "general", Type: "group", CreatedBy: members[0], CreatedAt: time.Now()}
+	if err := ts.db.CreateRoom(room); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	for _, userID := range members {
+		if err := ts.db.AddUserToRoom(userID.String(), room.ID.String()); err != nil {
+			t.Fatalf("AddUserToRoom: %v", err)
+		}
+	}
+	return room.ID
+}
+
+// do выполняет запрос от имени userID и декодирует ответ в out, если он задан
+func (ts *testServer) do(t *testing.T, userID uuid.UUID, method, path string, body io.Reader, contentType string, out interface{}) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.srv.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(testUserHeader, userID.String())
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+func (ts *testServer) doJSON(t *testing.T, userID uuid.UUID, method, path string, body interface{}, out interface{}) *http.Response {
+	t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	return ts.do(t, userID, method, path, r, "application/json", out)
+}
+
+// upload отправляет файлы в комнату multipart формой
+func (ts *testServer) upload(t *testing.T, userID, roomID uuid.UUID, files map[string][]byte, out interface{}) *http.Response {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, data := range files {
+		fw, err := mw.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+	}
+	mw.Close()
+
+	return ts.do(t, userID, http.MethodPost, "/api/v1/rooms/"+roomID.String()+"/attachments", &body, mw.FormDataContentType(), out)
+}
+
+// wsConn WebSocket клиент теста; кадры читаются в отдельной горутине
+type wsConn struct {
+	frames chan websocket.Message
+}
+
+func (ts *testServer) connect(t *testing.T, userID uuid.UUID, rooms int) *wsConn {
+	t.Helper()
+
+	header := http.Header{}
+	header.Set(testUserHeader, userID.String())
+
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.srv.URL, "http")+"/ws", header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	wc := &wsConn{frames: make(chan websocket.Message, 64)}
+	go func() {
+		defer close(wc.frames)
+		for {
+			var msg websocket.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			wc.frames <- msg
+		}
+	}()
+
+	// Подписка на комнаты пользователя подтверждается списком участников
+	for i := 0; i < rooms; i++ {
+		wc.expect(t, websocket.TypeRoomUsers)
+	}
+	return wc
+}
+
+func (wc *wsConn) expect(t *testing.T, msgType websocket.MessageType) websocket.Message {
+	t.Helper()
+
+	timeout := time.After(expectTimeout)
+	for {
+		select {
+		case msg, ok := <-wc.frames:
+			if !ok {
+				t.Fatalf("connection closed while waiting for %s", msgType)
+			}
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", msgType)
+		}
+	}
+}
+
+func (wc *wsConn) expectNone(t *testing.T, msgType websocket.MessageType) {
+	t.Helper()
+
+	timeout := time.After(silenceTimeout)
+	for {
+		select {
+		case msg, ok := <-wc.frames:
+			if !ok || msg.Type == msgType {
+				if ok {
+					t.Fatalf("unexpected %s: %s", msgType, msg.Data)
+				}
+				return
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSendMessageDeliveredOverWebSocket(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.createUser(t, "alice")
+	bob := ts.createUser(t, "bob")
+	carol := ts.createUser(t, "carol")
+	roomID := ts.createRoom(t, alice, bob)
+
+	bobConn := ts.connect(t, bob, 1)
+	carolConn := ts.connect(t, carol, 0)
+
+	var sent map[string]interface{}
+	resp := ts.doJSON(t, alice, http.MethodPost, "/api/v1/rooms/"+roomID.String()+"/messages", gin.H{"content": "hello"}, &sent)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", resp.StatusCode, sent)
+	}
+
+	msg := bobConn.expect(t, websocket.TypeMessage)
+	var payload dto.MessageResponse
+	if err := json.Unmarshal(msg.Data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Content != "hello" || payload.User.ID != alice {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	carolConn.expectNone(t, websocket.TypeMessage)
+
+	resp = ts.doJSON(t, carol, http.MethodPost, "/api/v1/rooms/"+roomID.String()+"/messages", gin.H{"content": "hi"}, nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("non-member send: expected 403, got %d", resp.StatusCode)
+	}
+
+	resp = ts.doJSON(t, alice, http.MethodPost, "/api/v1/rooms/"+roomID.String()+"/messages", gin.H{"content": "x", "type": "file"}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("file message without attachments: expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestEditAndDeleteRequireMembership(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.createUser(t, "alice")
+	bob := ts.createUser(t, "bob")
+	roomID := ts.createRoom(t, alice, bob)
+
+	var sent struct {
+		ID uuid.UUID `json:"id"`
+	}
+	ts.doJSON(t, alice, http.MethodPost, "/api/v1/rooms/"+roomID.String()+"/messages", gin.H{"content": "hello"}, &sent)
+
+	if err := ts.db.RemoveUserFromRoom(alice.String(), roomID.String()); err != nil {
+		t.Fatalf("RemoveUserFromRoom: %v", err)
+	}
+
+	resp := ts.doJSON(t, alice, http.MethodPut, "/api/v1/messages/"+sent.ID.String(), gin.H{"content": "edited"}, nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("edit after leaving: expected 403, got %d", resp.StatusCode)
+	}
+
+	resp = ts.doJSON(t, alice, http.MethodDelete, "/api/v1/messages/"+sent.ID.String(), nil, nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("delete after leaving: expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestReactionAddRemove(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.createUser(t, "alice")
+	bob := ts.createUser(t, "bob")
+	roomID := ts.createRoom(t, alice, bob)
+
+	var sent struct {
+		ID uuid.UUID `json:"id"`
+	}
+	ts.doJSON(t, alice, http.MethodPost, "/api/v1/rooms/"+roomID.String()+"/messages", gin.H{"content": "hello"}, &sent)
+
+	bobConn := ts.connect(t, bob, 1)
+	reactions := "/api/v1/messages/" + sent.ID.String() + "/reactions"
+
+	resp := ts.doJSON(t, alice, http.MethodPost, reactions, gin.H{"emoji": "+1"}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("add reaction: expected 200, got %d", resp.StatusCode)
+	}
+
+	var added dto.ReactionEvent
+	json.Unmarshal(bobConn.expect(t, websocket.TypeReactionAdded).Data, &added)
+	if added.Emoji != "+1" || added.Count != 1 || added.UserID != alice {
+		t.Fatalf("unexpected reaction_added: %+v", added)
+	}
+
+	// Повторная реакция тем же emoji не рассылается и не увеличивает счетчик
+	var repeated dto.ReactionEvent
+	resp = ts.doJSON(t, alice, http.MethodPost, reactions, gin.H{"emoji": "+1"}, &repeated)
+	if resp.StatusCode != http.StatusOK || repeated.Count != 1 {
+		t.Fatalf("repeat reaction: expected 200 with count 1, got %d %+v", resp.StatusCode, repeated)
+	}
+	bobConn.expectNone(t, websocket.TypeReactionAdded)
+
+	resp = ts.doJSON(t, alice, http.MethodDelete, reactions+"/+1", nil, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("remove reaction: expected 200, got %d", resp.StatusCode)
+	}
+
+	var removed dto.ReactionEvent
+	json.Unmarshal(bobConn.expect(t, websocket.TypeReactionRemoved).Data, &removed)
+	if removed.Count != 0 {
+		t.Fatalf("unexpected reaction_removed: %+v", removed)
+	}
+
+	// Повторное удаление ничего не удаляет и не рассылает
+	resp = ts.doJSON(t, alice, http.MethodDelete, reactions+"/+1", nil, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("remove missing reaction: expected 404, got %d", resp.StatusCode)
+	}
+	bobConn.expectNone(t, websocket.TypeReactionRemoved)
+}
+
+func TestAttachmentUploadAndServe(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.createUser(t, "alice")
+	bob := ts.createUser(t, "bob")
+	carol := ts.createUser(t, "carol")
+	roomID := ts.createRoom(t, alice, bob)
+
+	bobConn := ts.connect(t, bob, 1)
+
+	// Расширение берется из содержимого, а не из имени клиента
+	var message dto.MessageResponse
+	resp := ts.upload(t, alice, roomID, map[string][]byte{"evil.html": pngImage(t)}, &message)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: expected 201, got %d", resp.StatusCode)
+	}
+	if message.Type != "image" || len(message.Attachments) != 1 {
+		t.Fatalf("unexpected upload response: %+v", message)
+	}
+	url := message.Attachments[0].URL
+	if !strings.HasSuffix(url, "/evil.png") {
+		t.Fatalf("stored name must use the sniffed extension: %s", url)
+	}
+
+	// Загрузка рассылается как обычное сообщение
+	bobConn.expect(t, websocket.TypeMessage)
+
+	resp = ts.do(t, bob, http.MethodGet, url, nil, "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("serve: expected 200, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "image/png" {
+		t.Fatalf("unexpected Content-Type %q", got)
+	}
+	if got := resp.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Fatalf("missing nosniff, got %q", got)
+	}
+	if got := resp.Header.Get("Content-Disposition"); !strings.HasPrefix(got, "inline") {
+		t.Fatalf("images must be inline, got %q", got)
+	}
+
+	resp = ts.do(t, carol, http.MethodGet, url, nil, "", nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("serve to non-member: expected 403, got %d", resp.StatusCode)
+	}
+
+	// Не изображения отдаются только как загрузка
+	resp = ts.upload(t, alice, roomID, map[string][]byte{"notes.html": []byte("just some notes")}, &message)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload text: expected 201, got %d", resp.StatusCode)
+	}
+	url = message.Attachments[0].URL
+	if !strings.HasSuffix(url, "/notes.txt") {
+		t.Fatalf("stored name must use the sniffed extension: %s", url)
+	}
+
+	resp = ts.do(t, alice, http.MethodGet, url, nil, "", nil)
+	if got := resp.Header.Get("Content-Disposition"); !strings.HasPrefix(got, "attachment") {
+		t.Fatalf("non-images must be attachments, got %q", got)
+	}
+
+	resp = ts.upload(t, alice, roomID, map[string][]byte{"page.html": []byte("<html><script>alert(1)</script></html>")}, nil)
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("html upload: expected 415, got %d", resp.StatusCode)
+	}
+
+	resp = ts.upload(t, carol, roomID, map[string][]byte{"a.png": pngImage(t)}, nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("non-member upload: expected 403, got %d", resp.StatusCode)
+	}
+
+	// Файлы удаленного сообщения недоступны еще до очистки
+	resp = ts.do(t, alice, http.MethodDelete, "/api/v1/messages/"+message.ID.String(), nil, "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete message: expected 200, got %d", resp.StatusCode)
+	}
+	resp = ts.do(t, alice, http.MethodGet, url, nil, "", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("serve deleted attachment: expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestCreateRoomRejectsDirectAndBlockedMembers(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.createUser(t, "alice")
+	bob := ts.createUser(t, "bob")
+	carol := ts.createUser(t, "carol")
+
+	resp := ts.doJSON(t, alice, http.MethodPost, "/api/v1/rooms", gin.H{"name": "dm", "type": "direct", "member_ids": []uuid.UUID{bob}}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("direct room: expected 400, got %d", resp.StatusCode)
+	}
+
+	// Блокировка в любую сторону запрещает добавление
+	if err := ts.db.BlockUser(&models.UserBlock{BlockerID: carol, BlockedID: alice, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+	resp = ts.doJSON(t, alice, http.MethodPost, "/api/v1/rooms", gin.H{"name": "team", "type": "group", "member_ids": []uuid.UUID{bob, carol}}, nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("blocked member: expected 403, got %d", resp.StatusCode)
+	}
+
+	if rooms, _ := ts.db.GetUserRooms(alice.String()); len(rooms) != 0 {
+		t.Fatalf("rejected requests must not create rooms, got %d", len(rooms))
+	}
+}
+
+func TestCreateRoomAbortsWhenMemberCannotBeAdded(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.createUser(t, "alice")
+	bob := ts.createUser(t, "bob")
+	carol := ts.createUser(t, "carol")
+
+	resp := ts.doJSON(t, alice, http.MethodPost, "/api/v1/rooms", gin.H{"name": "team", "type": "group", "member_ids": []uuid.UUID{bob, uuid.New()}}, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown member: expected 404, got %d", resp.StatusCode)
+	}
+
+	resp = ts.doJSON(t, alice, http.MethodPost, "/api/v1/rooms", gin.H{"name": "team", "type": "group", "max_members": 2, "member_ids": []uuid.UUID{bob, carol}}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("over the member limit: expected 400, got %d", resp.StatusCode)
+	}
+
+	if rooms, _ := ts.db.GetUserRooms(bob.String()); len(rooms) != 0 {
+		t.Fatalf("failed requests must not leave rooms behind, got %d", len(rooms))
+	}
+}
@@ -1,23 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/dto"
 	"github.com/thereayou/discord-lite/internal/middleware"
-	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/services"
 )
 
 type HTTPMessageHandler struct {
-	db *database.Database
+	db       database.Repository
+	messages *services.MessageService
 }
 
-func NewHTTPMessageHandler(db *database.Database) *HTTPMessageHandler {
-	return &HTTPMessageHandler{db: db}
+func NewHTTPMessageHandler(db database.Repository, messages *services.MessageService) *HTTPMessageHandler {
+	return &HTTPMessageHandler{db: db, messages: messages}
 }
 
 // GetRoomMessages получает историю сообщений комнаты
@@ -61,16 +63,30 @@ func (h *HTTPMessageHandler) GetRoomMessages(c *gin.Context) {
 	}
 
 	// Получаем сообщения
-	messages, err := h.db.GetRoomMessages(roomID, limit, beforeID)
+	messages, err := h.db.GetRoomMessages(roomID, userID, limit, beforeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get messages"})
 		return
 	}
 
-	// Форматируем ответ
-	result := make([]gin.H, len(messages))
+	messageIDs := make([]uuid.UUID, len(messages))
 	for i, msg := range messages {
-		result[i] = formatMessageResponse(&msg)
+		messageIDs[i] = msg.ID
+	}
+
+	reactions, err := h.db.GetReactionCounts(messageIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get reactions"})
+		return
+	}
+
+	// Форматируем ответ
+	result := make([]dto.MessageResponse, len(messages))
+	for i := range messages {
+		result[i] = dto.NewMessageResponse(&messages[i], &messages[i].User)
+		if !messages[i].DeletedAt.Valid {
+			result[i].Reactions = toReactionSummaries(reactions[messages[i].ID])
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -82,37 +98,18 @@ func (h *HTTPMessageHandler) GetRoomMessages(c *gin.Context) {
 // SendMessage отправляет сообщение через HTTP (альтернатива WebSocket)
 func (h *HTTPMessageHandler) SendMessage(c *gin.Context) {
 	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
-	roomIDStr := c.Param("id")
 
-	roomID, err := uuid.Parse(roomIDStr)
+	roomID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room id"})
 		return
 	}
 
-	// Проверяем доступ к комнате
-	room, err := h.db.GetRoom(roomIDStr)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
-		return
-	}
-
-	isMember := false
-	for _, member := range room.Members {
-		if member.ID == userID {
-			isMember = true
-			break
-		}
-	}
-
-	if !isMember {
-		c.JSON(http.StatusForbidden, gin.H{"error": "you are not a member of this room"})
-		return
-	}
-
 	var req struct {
-		Content string `json:"content" binding:"required"`
-		Type    string `json:"type"`
+		Content   string     `json:"content" binding:"required"`
+		Type      string     `json:"type"`
+		ReplyToID *uuid.UUID `json:"reply_to_id"`
+		ThreadID  *uuid.UUID `json:"thread_id"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -120,44 +117,29 @@ func (h *HTTPMessageHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	msgType := "text"
-	if req.Type != "" {
-		msgType = req.Type
-	}
-
-	message := &models.Message{
+	message, err := h.messages.Send(services.SendMessageInput{
 		RoomID:    roomID,
 		UserID:    userID,
 		Content:   req.Content,
-		Type:      msgType,
-		CreatedAt: time.Now(),
-	}
-
-	if err := h.db.SaveMessage(message); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save message"})
+		Type:      req.Type,
+		ReplyToID: req.ReplyToID,
+		ThreadID:  req.ThreadID,
+	})
+	if err != nil {
+		respondMessageError(c, err, "failed to save message")
 		return
 	}
 
-	// Загружаем полную информацию о сообщении
-	fullMessage, _ := h.db.GetMessage(message.ID.String())
-
-	c.JSON(http.StatusCreated, formatMessageResponse(fullMessage))
+	c.JSON(http.StatusCreated, dto.NewMessageResponse(message, &message.User))
 }
 
 // UpdateMessage обновляет сообщение
 func (h *HTTPMessageHandler) UpdateMessage(c *gin.Context) {
 	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
-	messageID := c.Param("id")
 
-	message, err := h.db.GetMessage(messageID)
+	messageID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
-		return
-	}
-
-	// Только автор может редактировать
-	if message.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "you can only edit your own messages"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
 		return
 	}
 
@@ -170,66 +152,58 @@ func (h *HTTPMessageHandler) UpdateMessage(c *gin.Context) {
 		return
 	}
 
-	now := time.Now()
-	message.Content = req.Content
-	message.EditedAt = &now
-
-	if err := h.db.UpdateMessage(message); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update message"})
+	message, err := h.messages.Edit(messageID, userID, req.Content)
+	if err != nil {
+		respondMessageError(c, err, "failed to update message")
 		return
 	}
 
-	c.JSON(http.StatusOK, formatMessageResponse(message))
+	c.JSON(http.StatusOK, dto.NewMessageResponse(message, &message.User))
 }
 
 // DeleteMessage удаляет сообщение
 func (h *HTTPMessageHandler) DeleteMessage(c *gin.Context) {
 	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
-	messageID := c.Param("id")
 
-	message, err := h.db.GetMessage(messageID)
+	messageID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
-		return
-	}
-
-	// Только автор может удалять
-	if message.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "you can only delete your own messages"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
 		return
 	}
 
-	if err := h.db.DeleteMessage(messageID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete message"})
+	if _, err := h.messages.Delete(messageID, userID); err != nil {
+		respondMessageError(c, err, "failed to delete message")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "message deleted successfully"})
 }
 
-// formatMessageResponse форматирует ответ для сообщения
-func formatMessageResponse(msg *models.Message) gin.H {
-	response := gin.H{
-		"id":         msg.ID,
-		"room_id":    msg.RoomID,
-		"user_id":    msg.UserID,
-		"content":    msg.Content,
-		"type":       msg.Type,
-		"created_at": msg.CreatedAt,
-	}
-
-	if msg.EditedAt != nil {
-		response["edited_at"] = msg.EditedAt
-	}
-
-	// Если загружена информация о пользователе
-	if msg.User.ID != uuid.Nil {
-		response["user"] = gin.H{
-			"id":         msg.User.ID,
-			"username":   msg.User.Username,
-			"avatar_url": msg.User.AvatarURL,
-		}
+// respondMessageError переводит ошибку сервиса сообщений в HTTP ответ
+func respondMessageError(c *gin.Context, err error, fallback string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrMessageNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrNotRoomMember),
+		errors.Is(err, services.ErrCannotEditMessage),
+		errors.Is(err, services.ErrCannotDeleteMessage),
+		errors.Is(err, services.ErrCannotRestoreMessage):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrEmptyContent),
+		errors.Is(err, services.ErrContentTooLong),
+		errors.Is(err, services.ErrMissingAttachments),
+		errors.Is(err, services.ErrInvalidMessageType),
+		errors.Is(err, services.ErrInvalidReplyTo),
+		errors.Is(err, services.ErrInvalidThread):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrMessagePurged):
+		status = http.StatusGone
+	}
+
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": fallback})
+		return
 	}
-
-	return response
+	c.JSON(status, gin.H{"error": err.Error()})
 }
@@ -1,26 +1,32 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"github.com/thereayou/discord-lite/internal/handlers/dto"
+	"github.com/thereayou/discord-lite/internal/dto"
 	"log"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/thereayou/discord-lite/internal/database"
-	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/services"
 	"github.com/thereayou/discord-lite/internal/websocket"
+	"github.com/thereayou/discord-lite/pkg/ratelimit"
 )
 
 type MessageHandler struct {
-	db  *database.Database
-	hub *websocket.Hub
+	db       database.Repository
+	hub      *websocket.Hub
+	messages *services.MessageService
+	limiter  *ratelimit.Limiter
 }
 
-func NewMessageHandler(db *database.Database, hub *websocket.Hub) *MessageHandler {
+func NewMessageHandler(db database.Repository, hub *websocket.Hub, messages *services.MessageService, limiter *ratelimit.Limiter) *MessageHandler {
 	return &MessageHandler{
-		db:  db,
-		hub: hub,
+		db:       db,
+		hub:      hub,
+		messages: messages,
+		limiter:  limiter,
 	}
 }
 
@@ -35,6 +41,15 @@ func (h *MessageHandler) HandleMessage(client *websocket.Client, msg *websocket.
 	case websocket.TypeMessageDelete:
 		return h.handleMessageDelete(client, msg)
 
+	case websocket.TypeMessageRead:
+		return h.handleMessageRead(client, msg)
+
+	case websocket.TypeThreadSubscribe:
+		return h.handleThreadSubscribe(client, msg)
+
+	case websocket.TypeThreadUnsubscribe:
+		return h.handleThreadUnsubscribe(client, msg)
+
 	default:
 		log.Printf("Unknown message type: %s", msg.Type)
 		return nil
@@ -55,71 +70,46 @@ func (h *MessageHandler) handleTextMessage(client *websocket.Client, msg *websoc
 		return err
 	}
 
-	if payload.Content == "" {
-		return websocket.ErrInvalidMessage
-	}
-
-	msgType := "text"
-	if payload.Type != "" {
-		msgType = payload.Type
+	if !h.allowMessage(client, *msg.RoomID) {
+		return nil
 	}
 
-	message := &models.Message{
+	_, err := h.messages.Send(services.SendMessageInput{
 		RoomID:    *msg.RoomID,
 		UserID:    client.UserID,
 		Content:   payload.Content,
-		Type:      msgType,
-		CreatedAt: time.Now(),
-	}
-
-	if err := h.db.SaveMessage(message); err != nil {
-		log.Printf("Failed to save message: %v", err)
-		return err
-	}
-
-	user, err := h.db.GetUser(client.UserID.String())
+		Type:      payload.Type,
+		ReplyToID: payload.ReplyToID,
+		ThreadID:  payload.ThreadID,
+	})
 	if err != nil {
-		log.Printf("Failed to get user info: %v", err)
 		return err
 	}
 
-	response := dto.MessageResponse{
-		ID:        message.ID,
-		RoomID:    message.RoomID,
-		UserID:    message.UserID,
-		Content:   message.Content,
-		Type:      message.Type,
-		CreatedAt: message.CreatedAt,
-		User: dto.UserInfo{
-			ID:        user.ID,
-			Username:  user.Username,
-			AvatarURL: user.AvatarURL,
-		},
-	}
+	// Отправленное сообщение завершает набор текста
+	h.hub.StopTyping(client, *msg.RoomID)
 
-	wsMsg := websocket.Message{
-		Type:      websocket.TypeMessage,
-		RoomID:    msg.RoomID,
-		UserID:    client.UserID,
-		Timestamp: time.Now(),
-	}
+	return nil
+}
 
-	responseData, err := json.Marshal(response)
+// allowMessage списывает отправку из бюджетов пользователя и комнаты (общих с REST).
+// При превышении клиент получает error frame с retry_after в секундах.
+func (h *MessageHandler) allowMessage(client *websocket.Client, roomID uuid.UUID) bool {
+	result, err := h.limiter.AllowAll(context.Background(), middleware.MessageBudgets(client.UserID, roomID))
 	if err != nil {
-		return err
+		log.Printf("Rate limiter error: %v", err)
+		return true
 	}
-	wsMsg.Data = responseData
 
-	msgData, err := json.Marshal(wsMsg)
-	if err != nil {
-		return err
+	if !result.Allowed {
+		client.SendMessage(websocket.TypeError, map[string]interface{}{
+			"error":       "rate limit exceeded",
+			"room_id":     roomID,
+			"retry_after": middleware.Seconds(result.RetryAfter),
+		})
+		return false
 	}
-
-	h.hub.SendToRoom(*msg.RoomID, msgData)
-
-	go h.db.UpdateLastSeen(client.UserID.String())
-
-	return nil
+	return true
 }
 
 func (h *MessageHandler) handleMessageEdit(client *websocket.Client, msg *websocket.Message) error {
@@ -133,44 +123,8 @@ func (h *MessageHandler) handleMessageEdit(client *websocket.Client, msg *websoc
 		return err
 	}
 
-	message, err := h.db.GetMessage(payload.MessageID.String())
-	if err != nil {
-		return err
-	}
-
-	if message.UserID != client.UserID {
-		return websocket.ErrUnauthorized
-	}
-
-	now := time.Now()
-	message.Content = payload.Content
-	message.EditedAt = &now
-
-	if err := h.db.UpdateMessage(message); err != nil {
-		return err
-	}
-
-	// Отправляем обновление всем в комнате
-	response := map[string]interface{}{
-		"message_id": message.ID,
-		"content":    message.Content,
-		"edited_at":  message.EditedAt,
-	}
-
-	wsMsg := websocket.Message{
-		Type:      websocket.TypeMessageEdit,
-		RoomID:    &message.RoomID,
-		UserID:    client.UserID,
-		Timestamp: time.Now(),
-	}
-
-	responseData, _ := json.Marshal(response)
-	wsMsg.Data = responseData
-
-	msgData, _ := json.Marshal(wsMsg)
-	h.hub.SendToRoom(message.RoomID, msgData)
-
-	return nil
+	_, err := h.messages.Edit(payload.MessageID, client.UserID, payload.Content)
+	return err
 }
 
 func (h *MessageHandler) handleMessageDelete(client *websocket.Client, msg *websocket.Message) error {
@@ -183,63 +137,46 @@ func (h *MessageHandler) handleMessageDelete(client *websocket.Client, msg *webs
 		return err
 	}
 
-	message, err := h.db.GetMessage(payload.MessageID.String())
-	if err != nil {
+	_, err := h.messages.Delete(payload.MessageID, client.UserID)
+	return err
+}
+
+func (h *MessageHandler) handleMessageRead(client *websocket.Client, msg *websocket.Message) error {
+	var payload dto.ReadRequest
+	if err := json.Unmarshal(msg.Data, &payload); err != nil {
 		return err
 	}
 
-	if message.UserID != client.UserID {
-		return websocket.ErrUnauthorized
+	if payload.MessageID == uuid.Nil {
+		return websocket.ErrInvalidMessage
 	}
 
-	if err := h.db.DeleteMessage(payload.MessageID.String()); err != nil {
-		return err
-	}
+	_, err := markRoomRead(h.db, h.hub, client.UserID, msg.RoomID, payload.MessageID)
+	return err
+}
 
-	// Уведомляем всех в комнате
-	response := map[string]interface{}{
-		"message_id": payload.MessageID,
+// LoadRoomHistory загружает историю комнаты с реакциями с точки зрения userID
+func (h *MessageHandler) LoadRoomHistory(userID, roomID uuid.UUID, limit int, beforeID *uuid.UUID) ([]dto.MessageResponse, error) {
+	messages, err := h.db.GetRoomMessages(roomID.String(), userID, limit, beforeID)
+	if err != nil {
+		return nil, err
 	}
 
-	wsMsg := websocket.Message{
-		Type:      websocket.TypeMessageDelete,
-		RoomID:    &message.RoomID,
-		UserID:    client.UserID,
-		Timestamp: time.Now(),
+	messageIDs := make([]uuid.UUID, len(messages))
+	for i, msg := range messages {
+		messageIDs[i] = msg.ID
 	}
 
-	responseData, _ := json.Marshal(response)
-	wsMsg.Data = responseData
-
-	msgData, _ := json.Marshal(wsMsg)
-	h.hub.SendToRoom(message.RoomID, msgData)
-
-	return nil
-}
-
-func (h *MessageHandler) LoadRoomHistory(roomID uuid.UUID, limit int, beforeID *uuid.UUID) ([]dto.MessageResponse, error) {
-	messages, err := h.db.GetRoomMessages(roomID.String(), limit, beforeID)
+	reactions, err := h.db.GetReactionCounts(messageIDs, userID)
 	if err != nil {
 		return nil, err
 	}
 
 	responses := make([]dto.MessageResponse, len(messages))
-	for i, msg := range messages {
-		user, _ := h.db.GetUser(msg.UserID.String())
-
-		responses[i] = dto.MessageResponse{
-			ID:        msg.ID,
-			RoomID:    msg.RoomID,
-			UserID:    msg.UserID,
-			Content:   msg.Content,
-			Type:      msg.Type,
-			CreatedAt: msg.CreatedAt,
-			EditedAt:  msg.EditedAt,
-			User: dto.UserInfo{
-				ID:        user.ID,
-				Username:  user.Username,
-				AvatarURL: user.AvatarURL,
-			},
+	for i := range messages {
+		responses[i] = dto.NewMessageResponse(&messages[i], &messages[i].User)
+		if !messages[i].DeletedAt.Valid {
+			responses[i].Reactions = toReactionSummaries(reactions[messages[i].ID])
 		}
 	}
 
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+)
+
+// RestoreMessage восстанавливает удаленное сообщение (только admin комнаты)
+func (h *HTTPMessageHandler) RestoreMessage(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	messageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+
+	message, err := h.messages.Restore(messageID, userID)
+	if err != nil {
+		respondMessageError(c, err, "failed to restore message")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewMessageResponse(message, &message.User))
+}
+
+// GetMessageAuditLog возвращает журнал удалений и восстановлений сообщений (moderator и выше)
+func (h *RoomHandler) GetMessageAuditLog(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	room, ok := h.requireRole(c, userID, models.RoleModerator)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	var before *time.Time
+	if v := c.Query("before"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before value, expected RFC3339"})
+			return
+		}
+		before = &t
+	}
+
+	entries, err := h.db.GetMessageAuditLog(room.ID, limit, before)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get audit log"})
+		return
+	}
+
+	result := make([]gin.H, len(entries))
+	for i, entry := range entries {
+		result[i] = gin.H{
+			"id":         entry.ID,
+			"message_id": entry.MessageID,
+			"author_id":  entry.AuthorID,
+			"action":     entry.Action,
+			"actor": gin.H{
+				"id":       entry.Actor.ID,
+				"username": entry.Actor.Username,
+			},
+			"created_at": entry.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":  result,
+		"has_more": len(entries) == limit,
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/websocket"
+)
+
+// Сколько пользователей можно запросить одним запросом статусов
+const maxPresenceQuery = 200
+
+type PresenceHandler struct {
+	hub *websocket.Hub
+}
+
+func NewPresenceHandler(hub *websocket.Hub) *PresenceHandler {
+	return &PresenceHandler{hub: hub}
+}
+
+// UpdateMyPresence меняет статус текущего пользователя: online, idle, dnd или invisible,
+// с необязательным пользовательским текстом и сроком его действия
+func (h *PresenceHandler) UpdateMyPresence(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req websocket.PresenceUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.hub.SetPresence(userID, req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update presence"})
+		return
+	}
+
+	// Себе возвращаем выбранный статус, в том числе invisible
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":                  userID,
+		"status":                   req.Status,
+		"custom_status":            req.CustomStatus,
+		"custom_status_expires_at": req.CustomStatusExpiresAt,
+	})
+}
+
+// QueryPresence возвращает статусы списка пользователей; чужие для текущего пользователя всегда offline
+func (h *PresenceHandler) QueryPresence(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req struct {
+		UserIDs []uuid.UUID `json:"user_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.UserIDs) > maxPresenceQuery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many user ids"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"presence": h.hub.GetPresence(userID, req.UserIDs)})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/push"
+	"gorm.io/gorm"
+)
+
+type PushHandler struct {
+	db database.Repository
+
+	// Публичный VAPID ключ для подписки браузера, пустой если Web Push не настроен
+	vapidPublicKey string
+}
+
+func NewPushHandler(db database.Repository, vapidPublicKey string) *PushHandler {
+	return &PushHandler{db: db, vapidPublicKey: vapidPublicKey}
+}
+
+// GetVAPIDPublicKey возвращает ключ, которым браузер подписывается на Web Push
+func (h *PushHandler) GetVAPIDPublicKey(c *gin.Context) {
+	if h.vapidPublicKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "web push is not configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"public_key": h.vapidPublicKey})
+}
+
+// RegisterToken сохраняет токен устройства текущего пользователя
+func (h *PushHandler) RegisterToken(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Platform string `json:"platform" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidPlatform(req.Platform) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid platform"})
+		return
+	}
+
+	// Для web токен — подписка PushSubscription в JSON
+	if req.Platform == models.PlatformWeb {
+		if _, err := push.ParseWebPushSubscription(req.Token); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid web push subscription"})
+			return
+		}
+	}
+
+	now := time.Now()
+	token := &models.PushToken{
+		UserID:    userID,
+		Token:     req.Token,
+		Platform:  req.Platform,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := h.db.SavePushToken(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register push token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "push token registered"})
+}
+
+// UnregisterToken удаляет токен устройства, например при выходе из аккаунта
+func (h *PushHandler) UnregisterToken(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.DeletePushToken(userID, req.Token); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "push token not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unregister push token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "push token unregistered"})
+}
+
+// UpdateRoomNotifications отключает или включает уведомления комнаты.
+// Без muted_until комната отключена до явного включения.
+func (h *PushHandler) UpdateRoomNotifications(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	roomID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room id"})
+		return
+	}
+
+	var req struct {
+		Muted      *bool      `json:"muted" binding:"required"`
+		MutedUntil *time.Time `json:"muted_until"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MutedUntil != nil && !req.MutedUntil.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "muted_until must be in the future"})
+		return
+	}
+
+	if err := h.db.SetRoomMute(userID, roomID, *req.Muted, req.MutedUntil); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you are not a member of this room"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notification settings"})
+		return
+	}
+
+	mutedUntil := req.MutedUntil
+	if !*req.Muted {
+		mutedUntil = nil
+	}
+
+	c.JSON(http.StatusOK, gin.H{"room_id": roomID, "muted": *req.Muted, "muted_until": mutedUntil})
+}
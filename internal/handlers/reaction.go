@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/websocket"
+	"gorm.io/gorm"
+)
+
+type ReactionHandler struct {
+	db  database.Repository
+	hub *websocket.Hub
+}
+
+func NewReactionHandler(db database.Repository, hub *websocket.Hub) *ReactionHandler {
+	return &ReactionHandler{db: db, hub: hub}
+}
+
+// AddReaction добавляет реакцию текущего пользователя на сообщение
+func (h *ReactionHandler) AddReaction(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req dto.ReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message, ok := h.loadMessageForMember(c, userID)
+	if !ok {
+		return
+	}
+
+	reaction := &models.MessageReaction{
+		MessageID: message.ID,
+		UserID:    userID,
+		Emoji:     req.Emoji,
+		CreatedAt: time.Now(),
+	}
+
+	added, err := h.db.AddReaction(reaction)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add reaction"})
+		return
+	}
+
+	// Повторная реакция ничего не меняет: событие не рассылается
+	if !added {
+		c.JSON(http.StatusOK, h.reactionEvent(message, userID, req.Emoji))
+		return
+	}
+
+	event := h.broadcastReaction(websocket.TypeReactionAdded, message, userID, req.Emoji)
+
+	c.JSON(http.StatusOK, event)
+}
+
+// RemoveReaction удаляет реакцию текущего пользователя с сообщения
+func (h *ReactionHandler) RemoveReaction(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+	emoji := c.Param("emoji")
+
+	if emoji == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "emoji is required"})
+		return
+	}
+
+	message, ok := h.loadMessageForMember(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := h.db.RemoveReaction(message.ID, userID, emoji); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "reaction not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove reaction"})
+		return
+	}
+
+	event := h.broadcastReaction(websocket.TypeReactionRemoved, message, userID, emoji)
+
+	c.JSON(http.StatusOK, event)
+}
+
+// loadMessageForMember загружает сообщение и проверяет, что пользователь состоит в его комнате
+func (h *ReactionHandler) loadMessageForMember(c *gin.Context, userID uuid.UUID) (*models.Message, bool) {
+	message, err := h.db.GetMessage(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return nil, false
+	}
+
+	isMember, err := h.db.IsRoomMember(userID.String(), message.RoomID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check membership"})
+		return nil, false
+	}
+
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are not a member of this room"})
+		return nil, false
+	}
+
+	return message, true
+}
+
+// reactionEvent собирает событие с актуальным количеством реакций emoji
+func (h *ReactionHandler) reactionEvent(message *models.Message, userID uuid.UUID, emoji string) dto.ReactionEvent {
+	count, _ := h.db.CountReactions(message.ID, emoji)
+
+	return dto.ReactionEvent{
+		MessageID: message.ID,
+		UserID:    userID,
+		Emoji:     emoji,
+		Count:     count,
+	}
+}
+
+// broadcastReaction рассылает актуальное количество реакций всем в комнате
+func (h *ReactionHandler) broadcastReaction(msgType websocket.MessageType, message *models.Message, userID uuid.UUID, emoji string) dto.ReactionEvent {
+	event := h.reactionEvent(message, userID, emoji)
+
+	wsMsg := websocket.Message{
+		Type:      msgType,
+		RoomID:    &message.RoomID,
+		UserID:    userID,
+		Timestamp: time.Now(),
+	}
+
+	eventData, _ := json.Marshal(event)
+	wsMsg.Data = eventData
+
+	msgData, _ := json.Marshal(wsMsg)
+	h.hub.SendToRoomFrom(message.RoomID, userID, msgData)
+
+	return event
+}
+
+// toReactionSummaries преобразует агрегаты реакций в формат ответа
+func toReactionSummaries(counts []database.ReactionCount) []dto.ReactionSummary {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	summaries := make([]dto.ReactionSummary, len(counts))
+	for i, rc := range counts {
+		summaries[i] = dto.ReactionSummary{
+			Emoji:       rc.Emoji,
+			Count:       rc.Count,
+			ReactedByMe: rc.ReactedByMe,
+		}
+	}
+	return summaries
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/websocket"
+)
+
+var (
+	errMessageNotFound = errors.New("message not found")
+	errNotRoomMember   = errors.New("you are not a member of this room")
+)
+
+type ReadReceiptHandler struct {
+	db  database.Repository
+	hub *websocket.Hub
+}
+
+func NewReadReceiptHandler(db database.Repository, hub *websocket.Hub) *ReadReceiptHandler {
+	return &ReadReceiptHandler{db: db, hub: hub}
+}
+
+// MarkRead отмечает комнату прочитанной до указанного сообщения
+func (h *ReadReceiptHandler) MarkRead(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	roomID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room id"})
+		return
+	}
+
+	var req dto.ReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	receipt, err := markRoomRead(h.db, h.hub, userID, &roomID, req.MessageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, errMessageNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, errNotRoomMember):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark room as read"})
+		}
+		return
+	}
+
+	unread, _ := h.db.GetUnreadCount(userID, roomID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"receipt":      receipt,
+		"unread_count": unread,
+	})
+}
+
+// GetReadReceipts возвращает отметки прочтения участников комнаты
+func (h *ReadReceiptHandler) GetReadReceipts(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	roomID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room id"})
+		return
+	}
+
+	isMember, err := h.db.IsRoomMember(userID.String(), roomID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check membership"})
+		return
+	}
+
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": errNotRoomMember.Error()})
+		return
+	}
+
+	states, err := h.db.GetRoomReadStates(roomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get read receipts"})
+		return
+	}
+
+	result := make([]gin.H, len(states))
+	for i, state := range states {
+		result[i] = gin.H{
+			"user_id":      state.UserID,
+			"last_read_at": state.LastReadAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"receipts": result})
+}
+
+// markRoomRead отмечает комнату прочитанной до сообщения messageID, рассылает квитанцию
+// в комнату и новый счетчик непрочитанных всем соединениям пользователя.
+// Если roomID задан, сообщение должно принадлежать этой комнате.
+func markRoomRead(db database.Repository, hub *websocket.Hub, userID uuid.UUID, roomID *uuid.UUID, messageID uuid.UUID) (*dto.ReadReceipt, error) {
+	message, err := db.GetMessage(messageID.String())
+	if err != nil {
+		return nil, errMessageNotFound
+	}
+
+	if roomID != nil && message.RoomID != *roomID {
+		return nil, errMessageNotFound
+	}
+
+	isMember, err := db.IsRoomMember(userID.String(), message.RoomID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMember {
+		return nil, errNotRoomMember
+	}
+
+	receipt := &dto.ReadReceipt{
+		RoomID:    message.RoomID,
+		UserID:    userID,
+		MessageID: message.ID,
+		ReadAt:    message.CreatedAt,
+	}
+
+	advanced, err := db.MarkRoomRead(userID, message.RoomID, message.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Отметка не сдвинулась — рассылать нечего
+	if !advanced {
+		return receipt, nil
+	}
+
+	receiptMsg := websocket.Message{
+		Type:      websocket.TypeReadReceipt,
+		RoomID:    &message.RoomID,
+		UserID:    userID,
+		Timestamp: time.Now(),
+	}
+
+	receiptData, _ := json.Marshal(receipt)
+	receiptMsg.Data = receiptData
+
+	msgData, _ := json.Marshal(receiptMsg)
+	hub.SendToRoom(message.RoomID, msgData)
+
+	if unread, err := db.GetUnreadCount(userID, message.RoomID); err == nil {
+		countMsg := websocket.Message{
+			Type:      websocket.TypeUnreadCount,
+			RoomID:    &message.RoomID,
+			UserID:    userID,
+			Timestamp: time.Now(),
+		}
+
+		countData, _ := json.Marshal(dto.UnreadCount{RoomID: message.RoomID, UnreadCount: unread})
+		countMsg.Data = countData
+
+		msgData, _ := json.Marshal(countMsg)
+		hub.SendToUser(userID, msgData)
+	}
+
+	return receipt, nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+)
+
+// GetMessageRevisions возвращает историю правок сообщения участникам комнаты.
+// Прежний текст видят только автор и администраторы комнаты.
+func (h *HTTPMessageHandler) GetMessageRevisions(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	message, err := h.db.GetMessage(c.Param("id"))
+	if err != nil {
+		message, err = h.db.GetDeletedMessage(c.Param("id"))
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return
+	}
+
+	role, err := h.db.GetMemberRole(userID, message.RoomID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are not a member of this room"})
+		return
+	}
+
+	isAdmin := models.HasRole(role, models.RoleAdmin)
+
+	// История удаленного сообщения нужна только для модерации
+	if message.DeletedAt.Valid && !isAdmin {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return
+	}
+
+	revisions, err := h.db.GetMessageRevisions(message.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get revisions"})
+		return
+	}
+
+	showContent := isAdmin || message.UserID == userID
+
+	result := make([]dto.MessageRevision, len(revisions))
+	for i, rev := range revisions {
+		result[i] = dto.MessageRevision{
+			Version:    i + 1,
+			EditedBy:   rev.EditedBy,
+			ReplacedAt: rev.CreatedAt,
+		}
+		if showContent {
+			content := rev.Content
+			result[i].Content = &content
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message_id":     message.ID,
+		"revision_count": len(revisions),
+		"revisions":      result,
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -10,14 +11,15 @@ import (
 	"github.com/thereayou/discord-lite/internal/middleware"
 	"github.com/thereayou/discord-lite/internal/models"
 	"github.com/thereayou/discord-lite/internal/websocket"
+	"gorm.io/gorm"
 )
 
 type RoomHandler struct {
-	db  *database.Database
+	db  database.Repository
 	hub *websocket.Hub
 }
 
-func NewRoomHandler(db *database.Database, hub *websocket.Hub) *RoomHandler {
+func NewRoomHandler(db database.Repository, hub *websocket.Hub) *RoomHandler {
 	return &RoomHandler{db: db, hub: hub}
 }
 
@@ -30,6 +32,7 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 		Type       string   `json:"type" binding:"required,oneof=group direct"`
 		MemberIDs  []string `json:"member_ids"`
 		MaxMembers int      `json:"max_members"`
+		IsPrivate  bool     `json:"is_private"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -37,15 +40,54 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 		return
 	}
 
+	// Личные комнаты создаются только через CreateDirectRoom с его проверками
+	if req.Type == "direct" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "use /rooms/direct to create a direct room"})
+		return
+	}
+
+	memberIDs := make([]uuid.UUID, 0, len(req.MemberIDs))
+	seen := map[uuid.UUID]bool{userID: true}
+	for _, raw := range req.MemberIDs {
+		memberID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid member id"})
+			return
+		}
+		if seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+
+		// Нельзя добавить в комнату того, с кем есть блокировка в любую сторону
+		blocked, err := h.db.IsBlockedEither(userID, memberID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create room"})
+			return
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot add this user to the room"})
+			return
+		}
+
+		memberIDs = append(memberIDs, memberID)
+	}
+
 	maxMembers := req.MaxMembers
 	if maxMembers == 0 {
 		maxMembers = 20
 	}
 
+	if len(memberIDs)+1 > maxMembers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many members"})
+		return
+	}
+
 	room := &models.Room{
 		Name:       req.Name,
 		Type:       req.Type,
 		MaxMembers: maxMembers,
+		IsPrivate:  req.IsPrivate,
 		CreatedBy:  userID,
 		CreatedAt:  time.Now(),
 	}
@@ -55,21 +97,40 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 		return
 	}
 
-	// Добавляем создателя в комнату
+	// Добавляем создателя в комнату администратором
 	if err := h.db.AddUserToRoom(userID.String(), room.ID.String()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add creator to room"})
 		return
 	}
 
+	if err := h.db.SetMemberRole(userID, room.ID, models.RoleAdmin); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set creator role"})
+		return
+	}
+
 	// Добавляем других участников
-	for _, memberID := range req.MemberIDs {
-		if memberID != userID.String() {
-			h.db.AddUserToRoom(memberID, room.ID.String())
+	for _, memberID := range memberIDs {
+		if err := h.db.AddUserToRoom(memberID.String(), room.ID.String()); err != nil {
+			// Комната без части участников не нужна: удаляем и сообщаем об ошибке
+			h.db.DeleteRoom(room.ID.String())
+
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			case errors.Is(err, database.ErrRoomFull):
+				c.JSON(http.StatusBadRequest, gin.H{"error": "room is full"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add members to room"})
+			}
+			return
 		}
 	}
 
 	// Загружаем полную информацию о комнате
 	fullRoom, _ := h.db.GetRoom(room.ID.String())
+	if fullRoom != nil {
+		h.hub.InvalidateContacts(roomMemberIDs(fullRoom)...)
+	}
 
 	c.JSON(http.StatusCreated, formatRoomResponse(fullRoom))
 }
@@ -98,12 +159,45 @@ func (h *RoomHandler) CreateDirectRoom(c *gin.Context) {
 		return
 	}
 
+	blocked, err := h.db.IsBlockedEither(userID, targetUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create direct room"})
+		return
+	}
+
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot create direct room with this user"})
+		return
+	}
+
+	target, err := h.db.GetUser(targetUserID.String())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// Пользователь принимает личные сообщения только от друзей
+	if target.FriendsOnlyDirect {
+		friends, err := h.db.AreFriends(userID, targetUserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create direct room"})
+			return
+		}
+
+		if !friends {
+			c.JSON(http.StatusForbidden, gin.H{"error": "user accepts direct messages only from friends"})
+			return
+		}
+	}
+
 	room, err := h.db.GetOrCreateDirectRoom(userID, targetUserID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create direct room"})
 		return
 	}
 
+	h.hub.InvalidateContacts(userID, targetUserID)
+
 	c.JSON(http.StatusOK, formatRoomResponse(room))
 }
 
@@ -117,25 +211,37 @@ func (h *RoomHandler) GetMyRooms(c *gin.Context) {
 		return
 	}
 
+	unreadCounts, err := h.db.GetUnreadCounts(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get unread counts"})
+		return
+	}
+
 	// Добавляем информацию о последних сообщениях и количестве участников онлайн
 	roomsResponse := make([]gin.H, len(rooms))
 	for i, room := range rooms {
 		roomResponse := formatRoomResponse(&room)
 
 		// Получаем последнее сообщение
-		messages, _ := h.db.GetRoomMessages(room.ID.String(), 1, nil)
+		messages, _ := h.db.GetRoomMessages(room.ID.String(), userID, 1, nil)
 		if len(messages) > 0 {
-			roomResponse["last_message"] = gin.H{
+			lastMessage := gin.H{
 				"id":         messages[0].ID,
 				"content":    messages[0].Content,
 				"user_id":    messages[0].UserID,
 				"created_at": messages[0].CreatedAt,
 			}
+			if messages[0].DeletedAt.Valid {
+				lastMessage["content"] = ""
+				lastMessage["deleted"] = true
+			}
+			roomResponse["last_message"] = lastMessage
 		}
 
 		// Получаем количество участников онлайн
 		onlineUsers := h.hub.GetRoomUsers(room.ID)
 		roomResponse["online_count"] = len(onlineUsers)
+		roomResponse["unread_count"] = unreadCounts[room.ID]
 
 		roomsResponse[i] = roomResponse
 	}
@@ -185,15 +291,17 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 		return
 	}
 
-	// Проверяем права (только создатель может обновлять)
-	if room.CreatedBy != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "only room creator can update room"})
+	// Проверяем права (обновлять могут администраторы комнаты)
+	role, err := h.db.GetMemberRole(userID, room.ID)
+	if err != nil || !models.HasRole(role, models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only room admins can update room"})
 		return
 	}
 
 	var req struct {
 		Name       string `json:"name"`
 		MaxMembers int    `json:"max_members"`
+		IsPrivate  *bool  `json:"is_private"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -208,6 +316,9 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 	if req.MaxMembers > 0 {
 		room.MaxMembers = req.MaxMembers
 	}
+	if req.IsPrivate != nil && room.Type == "group" {
+		room.IsPrivate = *req.IsPrivate
+	}
 
 	if err := h.db.UpdateRoom(room); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update room"})
@@ -239,6 +350,8 @@ func (h *RoomHandler) DeleteRoom(c *gin.Context) {
 		return
 	}
 
+	h.hub.InvalidateContacts(roomMemberIDs(room)...)
+
 	c.JSON(http.StatusOK, gin.H{"message": "room deleted successfully"})
 }
 
@@ -259,6 +372,24 @@ func (h *RoomHandler) JoinRoom(c *gin.Context) {
 		return
 	}
 
+	// В приватную группу вступают только по приглашению
+	if room.IsPrivate {
+		c.JSON(http.StatusForbidden, gin.H{"error": "room is private, an invite is required"})
+		return
+	}
+
+	// Забаненные не могут вернуться
+	banned, err := h.db.IsBanned(userID, room.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to join room"})
+		return
+	}
+
+	if banned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are banned from this room"})
+		return
+	}
+
 	// Проверяем лимит участников
 	if len(room.Members) >= room.MaxMembers {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "room is full"})
@@ -266,10 +397,17 @@ func (h *RoomHandler) JoinRoom(c *gin.Context) {
 	}
 
 	if err := h.db.AddUserToRoom(userID.String(), roomID); err != nil {
+		if errors.Is(err, database.ErrRoomFull) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "room is full"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to join room"})
 		return
 	}
 
+	// Новый участник и участники комнаты теперь видят статусы друг друга
+	h.hub.InvalidateContacts(append(roomMemberIDs(room), userID)...)
+
 	c.JSON(http.StatusOK, gin.H{"message": "joined room successfully"})
 }
 
@@ -301,6 +439,9 @@ func (h *RoomHandler) LeaveRoom(c *gin.Context) {
 		return
 	}
 
+	// Сокеты пользователя больше не должны получать события комнаты
+	h.hub.RemoveUserFromRoom(userID, room.ID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "left room successfully"})
 }
 
@@ -329,6 +470,12 @@ func (h *RoomHandler) GetRoomMembers(c *gin.Context) {
 		return
 	}
 
+	roles, err := h.db.GetRoomMemberRoles(room.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get member roles"})
+		return
+	}
+
 	// Форматируем список участников
 	members := make([]gin.H, len(room.Members))
 	onlineUsers := h.hub.GetRoomUsers(room.ID)
@@ -349,6 +496,11 @@ func (h *RoomHandler) GetRoomMembers(c *gin.Context) {
 			"last_seen_at": member.LastSeenAt,
 			"is_online":    isOnline,
 			"is_creator":   member.ID == room.CreatedBy,
+			"role":         roles[member.ID],
+		}
+
+		if member.ID == room.CreatedBy {
+			members[i]["role"] = models.RoleAdmin
 		}
 	}
 
@@ -371,8 +523,18 @@ func formatRoomResponse(room *models.Room) gin.H {
 		"name":        room.Name,
 		"type":        room.Type,
 		"max_members": room.MaxMembers,
+		"is_private":  room.IsPrivate,
 		"created_by":  room.CreatedBy,
 		"created_at":  room.CreatedAt,
 		"members":     members,
 	}
 }
+
+// roomMemberIDs возвращает ID участников комнаты
+func roomMemberIDs(room *models.Room) []uuid.UUID {
+	ids := make([]uuid.UUID, len(room.Members))
+	for i, member := range room.Members {
+		ids[i] = member.ID
+	}
+	return ids
+}
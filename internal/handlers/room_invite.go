@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// Срок действия приглашения, если он не указан
+	defaultInviteTTL = 7 * 24 * time.Hour
+
+	// Максимальный срок действия приглашения
+	maxInviteTTL = 30 * 24 * time.Hour
+)
+
+// CreateInvite создает приглашение в комнату (только admin).
+// expires_in в секундах: не указан — 7 дней, 0 — бессрочно. max_uses 0 — без ограничения.
+func (h *RoomHandler) CreateInvite(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	var req struct {
+		MaxUses   int  `json:"max_uses" binding:"min=0,max=1000"`
+		ExpiresIn *int `json:"expires_in" binding:"omitempty,min=0"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := defaultInviteTTL
+	if req.ExpiresIn != nil {
+		ttl = time.Duration(*req.ExpiresIn) * time.Second
+	}
+	if ttl > maxInviteTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invite expiry is too long"})
+		return
+	}
+
+	room, ok := h.requireRole(c, userID, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	if room.Type == "direct" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot create invites for direct room"})
+		return
+	}
+
+	invite := &models.RoomInvite{
+		RoomID:    room.ID,
+		CreatedBy: userID,
+		MaxUses:   req.MaxUses,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		expiresAt := invite.CreatedAt.Add(ttl)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	if err := h.db.CreateRoomInvite(invite); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, formatInviteResponse(invite))
+}
+
+// GetInvites возвращает действующие и истекшие, но не отозванные приглашения комнаты (только admin)
+func (h *RoomHandler) GetInvites(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	room, ok := h.requireRole(c, userID, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	invites, err := h.db.GetRoomInvites(room.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get invites"})
+		return
+	}
+
+	result := make([]gin.H, len(invites))
+	for i := range invites {
+		result[i] = formatInviteResponse(&invites[i])
+		result[i]["created_by"] = gin.H{
+			"id":       invites[i].Creator.ID,
+			"username": invites[i].Creator.Username,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invites": result})
+}
+
+// RevokeInvite отзывает приглашение (только admin)
+func (h *RoomHandler) RevokeInvite(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	room, ok := h.requireRole(c, userID, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := h.db.RevokeRoomInvite(room.ID, c.Param("code")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invite not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invite revoked"})
+}
+
+// GetInvite показывает, куда ведет приглашение, до вступления
+func (h *RoomHandler) GetInvite(c *gin.Context) {
+	invite, err := h.db.GetRoomInvite(c.Param("code"))
+	if err != nil || !invite.IsUsable(time.Now()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": database.ErrInviteInvalid.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":       invite.Code,
+		"expires_at": invite.ExpiresAt,
+		"room": gin.H{
+			"id":           invite.Room.ID,
+			"name":         invite.Room.Name,
+			"member_count": len(invite.Room.Members),
+			"max_members":  invite.Room.MaxMembers,
+		},
+	})
+}
+
+// AcceptInvite добавляет текущего пользователя в комнату по приглашению
+func (h *RoomHandler) AcceptInvite(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	// Членство и бан проверяются внутри транзакции, иначе параллельные запросы их обходят
+	invite, err := h.db.AcceptRoomInvite(c.Param("code"), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInviteInvalid):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, database.ErrBannedFromRoom):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, database.ErrRoomFull):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to accept invite"})
+		}
+		return
+	}
+
+	room, err := h.db.GetRoom(invite.RoomID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load room"})
+		return
+	}
+
+	// Новый участник и участники комнаты теперь видят статусы друг друга
+	h.hub.InvalidateContacts(roomMemberIDs(room)...)
+
+	c.JSON(http.StatusOK, formatRoomResponse(room))
+}
+
+// formatInviteResponse форматирует ответ для приглашения
+func formatInviteResponse(invite *models.RoomInvite) gin.H {
+	return gin.H{
+		"code":       invite.Code,
+		"room_id":    invite.RoomID,
+		"uses":       invite.Uses,
+		"max_uses":   invite.MaxUses,
+		"expires_at": invite.ExpiresAt,
+		"created_at": invite.CreatedAt,
+		"usable":     invite.IsUsable(time.Now()),
+	}
+}
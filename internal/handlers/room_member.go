@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/websocket"
+	"gorm.io/gorm"
+)
+
+// memberModeration результат проверки прав на действие над участником
+type memberModeration struct {
+	room     *models.Room
+	actorID  uuid.UUID
+	targetID uuid.UUID
+}
+
+// UpdateMemberRole назначает участнику роль (только admin)
+func (h *RoomHandler) UpdateMemberRole(c *gin.Context) {
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role"})
+		return
+	}
+
+	m, ok := h.checkModeration(c, true)
+	if !ok {
+		return
+	}
+
+	if err := h.db.SetMemberRole(m.targetID, m.room.ID, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update role"})
+		return
+	}
+
+	h.broadcastMemberEvent(websocket.TypeMemberRoleChanged, m, false, gin.H{
+		"user_id":    m.targetID,
+		"role":       req.Role,
+		"changed_by": m.actorID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"user_id": m.targetID, "role": req.Role})
+}
+
+// KickMember исключает участника из комнаты (только admin)
+func (h *RoomHandler) KickMember(c *gin.Context) {
+	m, ok := h.checkModeration(c, false)
+	if !ok {
+		return
+	}
+
+	if err := h.db.RemoveUserFromRoom(m.targetID.String(), m.room.ID.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to kick member"})
+		return
+	}
+
+	h.hub.RemoveUserFromRoom(m.targetID, m.room.ID)
+
+	h.broadcastMemberEvent(websocket.TypeMemberKicked, m, true, gin.H{
+		"user_id":   m.targetID,
+		"kicked_by": m.actorID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "member kicked"})
+}
+
+// BanMember исключает участника и запрещает ему возвращаться (только admin)
+func (h *RoomHandler) BanMember(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"max=500"`
+	}
+
+	// Тело необязательно
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	m, ok := h.checkModeration(c, false)
+	if !ok {
+		return
+	}
+
+	ban := &models.RoomBan{
+		RoomID:    m.room.ID,
+		UserID:    m.targetID,
+		BannedBy:  m.actorID,
+		Reason:    req.Reason,
+		CreatedAt: time.Now(),
+	}
+
+	if err := h.db.BanUser(ban); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to ban member"})
+		return
+	}
+
+	h.hub.RemoveUserFromRoom(m.targetID, m.room.ID)
+
+	h.broadcastMemberEvent(websocket.TypeMemberBanned, m, true, gin.H{
+		"user_id":   m.targetID,
+		"banned_by": m.actorID,
+		"reason":    req.Reason,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "member banned"})
+}
+
+// UnbanMember снимает бан (только admin)
+func (h *RoomHandler) UnbanMember(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	room, ok := h.requireRole(c, userID, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.db.UnbanUser(targetID, room.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "ban not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unban member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "member unbanned"})
+}
+
+// GetRoomBans возвращает список забаненных (moderator и выше)
+func (h *RoomHandler) GetRoomBans(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	room, ok := h.requireRole(c, userID, models.RoleModerator)
+	if !ok {
+		return
+	}
+
+	bans, err := h.db.GetRoomBans(room.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get bans"})
+		return
+	}
+
+	result := make([]gin.H, len(bans))
+	for i, ban := range bans {
+		result[i] = gin.H{
+			"user_id":    ban.UserID,
+			"username":   ban.User.Username,
+			"banned_by":  ban.BannedBy,
+			"reason":     ban.Reason,
+			"created_at": ban.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bans": result})
+}
+
+// requireRole загружает комнату и проверяет, что у пользователя есть требуемая роль
+func (h *RoomHandler) requireRole(c *gin.Context, userID uuid.UUID, required string) (*models.Room, bool) {
+	room, err := h.db.GetRoom(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return nil, false
+	}
+
+	role, err := h.db.GetMemberRole(userID, room.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you are not a member of this room"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
+		return nil, false
+	}
+
+	if !models.HasRole(role, required) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+		return nil, false
+	}
+
+	return room, true
+}
+
+// checkModeration проверяет, что текущий пользователь — admin и может управлять целевым участником.
+// Создатель комнаты неприкосновенен; admin не может трогать других admin, кроме как создатель.
+// allowSelf разрешает действие над собой (например, понижение своей роли).
+func (h *RoomHandler) checkModeration(c *gin.Context, allowSelf bool) (*memberModeration, bool) {
+	actorID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	room, ok := h.requireRole(c, actorID, models.RoleAdmin)
+	if !ok {
+		return nil, false
+	}
+
+	if room.Type == "direct" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "direct rooms have no roles"})
+		return nil, false
+	}
+
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return nil, false
+	}
+
+	if targetID == room.CreatedBy {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot moderate room creator"})
+		return nil, false
+	}
+
+	if targetID == actorID && !allowSelf {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot moderate yourself"})
+		return nil, false
+	}
+
+	targetRole, err := h.db.GetMemberRole(targetID, room.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user is not a member of this room"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
+		return nil, false
+	}
+
+	// Admin не может управлять другим admin, это может только создатель
+	if targetID != actorID && actorID != room.CreatedBy && !models.RoleOutranks(models.RoleAdmin, targetRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot moderate a member with equal role"})
+		return nil, false
+	}
+
+	return &memberModeration{room: room, actorID: actorID, targetID: targetID}, true
+}
+
+// broadcastMemberEvent рассылает событие в комнату. notifyTarget дополнительно отправляет
+// его лично участнику, который уже отписан от комнаты (kick, ban).
+func (h *RoomHandler) broadcastMemberEvent(msgType websocket.MessageType, m *memberModeration, notifyTarget bool, data gin.H) {
+	wsMsg := websocket.Message{
+		Type:      msgType,
+		RoomID:    &m.room.ID,
+		UserID:    m.actorID,
+		Timestamp: time.Now(),
+	}
+
+	eventData, _ := json.Marshal(data)
+	wsMsg.Data = eventData
+
+	msgData, _ := json.Marshal(wsMsg)
+	h.hub.SendToRoom(m.room.ID, msgData)
+	if notifyTarget {
+		h.hub.SendToUser(m.targetID, msgData)
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/middleware"
+)
+
+const maxSearchQueryLength = 200
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// SearchMessages ищет сообщения по тексту в комнатах текущего пользователя
+func (h *HTTPMessageHandler) SearchMessages(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return
+	}
+	if len(q) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is too long"})
+		return
+	}
+
+	params := database.MessageSearchParams{
+		Query:  q,
+		UserID: userID,
+		Limit:  20,
+	}
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 50 {
+			params.Limit = parsed
+		}
+	}
+
+	if v := c.Query("room_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room id"})
+			return
+		}
+		params.RoomID = &id
+	}
+
+	if v := c.Query("author_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid author id"})
+			return
+		}
+		params.AuthorID = &id
+	}
+
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date, expected RFC3339"})
+			return
+		}
+		params.From = &t
+	}
+
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date, expected RFC3339"})
+			return
+		}
+		params.To = &t
+	}
+
+	if v := c.Query("has_attachment"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid has_attachment value"})
+			return
+		}
+		params.HasAttachment = &b
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := decodeSearchCursor(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		params.After = cursor
+	}
+
+	results, err := h.db.SearchMessages(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search messages"})
+		return
+	}
+
+	messageIDs := make([]uuid.UUID, len(results))
+	for i, r := range results {
+		messageIDs[i] = r.Message.ID
+	}
+
+	reactions, err := h.db.GetReactionCounts(messageIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get reactions"})
+		return
+	}
+
+	items := make([]dto.SearchResultResponse, len(results))
+	for i := range results {
+		items[i] = dto.SearchResultResponse{
+			MessageResponse: dto.NewMessageResponse(&results[i].Message, &results[i].Message.User),
+			Snippet:         results[i].Snippet,
+		}
+		items[i].Reactions = toReactionSummaries(reactions[results[i].Message.ID])
+	}
+
+	response := gin.H{"results": items}
+	if len(results) == params.Limit {
+		last := results[len(results)-1].Message
+		response["next_cursor"] = encodeSearchCursor(database.MessageSearchCursor{
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// encodeSearchCursor упаковывает позицию выдачи в непрозрачную строку
+func encodeSearchCursor(cursor database.MessageSearchCursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(s string) (*database.MessageSearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	messageID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &database.MessageSearchCursor{CreatedAt: createdAt, ID: messageID}, nil
+}
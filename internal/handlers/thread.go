@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/middleware"
+	"github.com/thereayou/discord-lite/internal/services"
+	"github.com/thereayou/discord-lite/internal/websocket"
+)
+
+// GetThread возвращает корневое сообщение треда и страницу его ответов
+func (h *HTTPMessageHandler) GetThread(c *gin.Context) {
+	userID := c.MustGet(middleware.UserIDKey).(uuid.UUID)
+
+	// Тред удаленного сообщения остается доступным, корень показывается заглушкой
+	root, err := h.db.GetMessage(c.Param("id"))
+	if err != nil {
+		root, err = h.db.GetDeletedMessage(c.Param("id"))
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return
+	}
+
+	isMember, err := h.db.IsRoomMember(userID.String(), root.RoomID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check membership"})
+		return
+	}
+
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are not a member of this room"})
+		return
+	}
+
+	if root.ThreadID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "message is a thread reply, not a thread root"})
+		return
+	}
+
+	if author, err := h.db.GetUser(root.UserID.String()); err == nil {
+		root.User = *author
+	}
+
+	// Параметры пагинации
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	var beforeID *uuid.UUID
+	if before := c.Query("before"); before != "" {
+		if id, err := uuid.Parse(before); err == nil {
+			beforeID = &id
+		}
+	}
+
+	replies, err := h.db.GetThreadReplies(root.ID, userID, limit, beforeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get thread replies"})
+		return
+	}
+
+	messageIDs := make([]uuid.UUID, 0, len(replies)+1)
+	messageIDs = append(messageIDs, root.ID)
+	for _, msg := range replies {
+		messageIDs = append(messageIDs, msg.ID)
+	}
+
+	reactions, err := h.db.GetReactionCounts(messageIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get reactions"})
+		return
+	}
+
+	parent := dto.NewMessageResponse(root, &root.User)
+	if !root.DeletedAt.Valid {
+		parent.Reactions = toReactionSummaries(reactions[root.ID])
+	}
+
+	result := make([]dto.MessageResponse, len(replies))
+	for i := range replies {
+		result[i] = dto.NewMessageResponse(&replies[i], &replies[i].User)
+		if !replies[i].DeletedAt.Valid {
+			result[i].Reactions = toReactionSummaries(reactions[replies[i].ID])
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"parent":   parent,
+		"replies":  result,
+		"has_more": len(replies) == limit,
+	})
+}
+
+func (h *MessageHandler) handleThreadSubscribe(client *websocket.Client, msg *websocket.Message) error {
+	if msg.ThreadID == nil {
+		return websocket.ErrInvalidMessage
+	}
+
+	root, err := h.db.GetMessage(msg.ThreadID.String())
+	if err != nil || root.ThreadID != nil {
+		return services.ErrInvalidThread
+	}
+
+	return h.hub.SubscribeThread(client, root.RoomID, root.ID)
+}
+
+func (h *MessageHandler) handleThreadUnsubscribe(client *websocket.Client, msg *websocket.Message) error {
+	if msg.ThreadID == nil {
+		return websocket.ErrInvalidMessage
+	}
+
+	h.hub.UnsubscribeThread(client, *msg.ThreadID)
+	return nil
+}
@@ -9,10 +9,10 @@ import (
 )
 
 type UserHandler struct {
-	db *database.Database
+	db database.UserRepository
 }
 
-func NewUserHandler(db *database.Database) *UserHandler {
+func NewUserHandler(db database.UserRepository) *UserHandler {
 	return &UserHandler{db: db}
 }
 
@@ -33,6 +33,8 @@ func (h *UserHandler) GetMe(c *gin.Context) {
 		"avatar_url":   user.AvatarURL,
 		"created_at":   user.CreatedAt,
 		"last_seen_at": user.LastSeenAt,
+
+		"friends_only_direct": user.FriendsOnlyDirect,
 	})
 }
 
@@ -43,6 +45,9 @@ func (h *UserHandler) UpdateMe(c *gin.Context) {
 	var req struct {
 		Username  string `json:"username"`
 		AvatarURL string `json:"avatar_url"`
+
+		// Личные комнаты только с друзьями; nil — не менять
+		FriendsOnlyDirect *bool `json:"friends_only_direct"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -63,6 +68,9 @@ func (h *UserHandler) UpdateMe(c *gin.Context) {
 	if req.AvatarURL != "" {
 		user.AvatarURL = req.AvatarURL
 	}
+	if req.FriendsOnlyDirect != nil {
+		user.FriendsOnlyDirect = *req.FriendsOnlyDirect
+	}
 
 	if err := h.db.UpdateUser(user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
@@ -74,6 +82,8 @@ func (h *UserHandler) UpdateMe(c *gin.Context) {
 		"username":   user.Username,
 		"email":      user.Email,
 		"avatar_url": user.AvatarURL,
+
+		"friends_only_direct": user.FriendsOnlyDirect,
 	})
 }
 
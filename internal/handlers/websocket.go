@@ -2,24 +2,28 @@ package handlers
 
 import (
 	"github.com/google/uuid"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/thereayou/discord-lite/internal/database"
 	"github.com/thereayou/discord-lite/internal/middleware"
 	ws "github.com/thereayou/discord-lite/internal/websocket"
 )
 
 // WebSocketHandler управляет WebSocket соединениями
 type WebSocketHandler struct {
+	db             database.Repository
 	hub            *ws.Hub
 	messageHandler *MessageHandler
 	upgrader       websocket.Upgrader
 }
 
 // NewWebSocketHandler создает новый WebSocket handler
-func NewWebSocketHandler(hub *ws.Hub, messageHandler *MessageHandler) *WebSocketHandler {
+func NewWebSocketHandler(db database.Repository, hub *ws.Hub, messageHandler *MessageHandler) *WebSocketHandler {
 	return &WebSocketHandler{
+		db:             db,
 		hub:            hub,
 		messageHandler: messageHandler,
 		upgrader: websocket.Upgrader{
@@ -47,10 +51,32 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	client := ws.NewClient(h.hub, conn, userID.(uuid.UUID))
+	client := ws.NewClient(h.hub, conn, userID.(uuid.UUID), c.GetString(middleware.SessionIDKey))
+
+	// Блокировки нужны хабу для фильтрации сообщений и статусов
+	blocked, err := h.db.GetBlockedIDs(client.UserID)
+	if err != nil {
+		log.Printf("Failed to load blocks for user %s: %v", client.UserID, err)
+	}
+	blockedBy, err := h.db.GetBlockerIDs(client.UserID)
+	if err != nil {
+		log.Printf("Failed to load blockers for user %s: %v", client.UserID, err)
+	}
+	h.hub.SetUserBlocks(client.UserID, blocked, blockedBy)
 
 	h.hub.Register(client)
 
+	// Подписываем на все комнаты пользователя; остальные доступны через room_join с проверкой членства
+	rooms, err := h.db.GetUserRooms(client.UserID.String())
+	if err != nil {
+		log.Printf("Failed to load rooms for user %s: %v", client.UserID, err)
+	}
+	roomIDs := make([]uuid.UUID, len(rooms))
+	for i, room := range rooms {
+		roomIDs[i] = room.ID
+	}
+	h.hub.SubscribeRooms(client, roomIDs)
+
 	go client.WritePump()
 	go client.ReadPump(h.messageHandler)
 }
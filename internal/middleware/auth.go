@@ -11,10 +11,13 @@ import (
 	"github.com/thereayou/discord-lite/pkg/auth"
 )
 
-const UserIDKey = "userID"
+const (
+	UserIDKey    = "userID"
+	SessionIDKey = "sessionID"
+)
 
 // AuthMiddleware проверяет JWT токен
-func AuthMiddleware(jwtManager *auth.JWTManager, redisClient *redis.Client) gin.HandlerFunc {
+func AuthMiddleware(jwtManager *auth.JWTManager, sessions *auth.SessionManager, redisClient *redis.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token, err := auth.ExtractTokenFromHeader(c.Request)
 		if err != nil {
@@ -45,13 +48,22 @@ func AuthMiddleware(jwtManager *auth.JWTManager, redisClient *redis.Client) gin.
 			return
 		}
 
+		// Токен отозванной сессии недействителен до истечения
+		active, err := sessions.IsActive(context.Background(), claims.SessionID)
+		if err != nil || !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set(UserIDKey, userID)
+		c.Set(SessionIDKey, claims.SessionID)
 		c.Next()
 	}
 }
 
 // WSAuthMiddleware специальный middleware для WebSocket
-func WSAuthMiddleware(jwtManager *auth.JWTManager, redisClient *redis.Client) gin.HandlerFunc {
+func WSAuthMiddleware(jwtManager *auth.JWTManager, sessions *auth.SessionManager, redisClient *redis.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.Query("token")
 		if token == "" {
@@ -92,7 +104,16 @@ func WSAuthMiddleware(jwtManager *auth.JWTManager, redisClient *redis.Client) gi
 			return
 		}
 
+		// Токен отозванной сессии недействителен до истечения
+		active, err := sessions.IsActive(context.Background(), claims.SessionID)
+		if err != nil || !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set(UserIDKey, userID)
+		c.Set(SessionIDKey, claims.SessionID)
 		c.Next()
 	}
 }
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/pkg/ratelimit"
+)
+
+// Бюджеты запросов
+var (
+	// Регистрация, вход и обновление токенов с одного IP
+	RegisterLimit = ratelimit.PerHour(10, 5)
+	LoginLimit    = ratelimit.PerMinute(20, 10)
+	RefreshLimit  = ratelimit.PerMinute(60, 20)
+
+	// Сообщения: пользователь и комната в целом, общий бюджет для REST и WebSocket
+	MessageUserLimit = ratelimit.PerMinute(30, 10)
+	MessageRoomLimit = ratelimit.PerMinute(300, 60)
+
+	// Заявки в друзья от одного пользователя
+	FriendRequestLimit = ratelimit.PerHour(30, 10)
+
+	// Вступление по приглашениям: мешает перебирать коды
+	InviteAcceptLimit = ratelimit.PerMinute(10, 5)
+)
+
+// BudgetFunc выбирает бюджеты, из которых списывается запрос
+type BudgetFunc func(c *gin.Context) []ratelimit.Budget
+
+// ByIP бюджет name на IP клиента
+func ByIP(name string, limit ratelimit.Limit) BudgetFunc {
+	return func(c *gin.Context) []ratelimit.Budget {
+		return []ratelimit.Budget{{Key: name + ":ip:" + c.ClientIP(), Limit: limit}}
+	}
+}
+
+// ByUser бюджет name на текущего пользователя
+func ByUser(name string, limit ratelimit.Limit) BudgetFunc {
+	return func(c *gin.Context) []ratelimit.Budget {
+		userID := c.MustGet(UserIDKey).(uuid.UUID)
+		return []ratelimit.Budget{{Key: name + ":user:" + userID.String(), Limit: limit}}
+	}
+}
+
+// MessageBudgets бюджеты отправки сообщения пользователем в комнату
+func MessageBudgets(userID, roomID uuid.UUID) []ratelimit.Budget {
+	return []ratelimit.Budget{
+		{Key: "message:user:" + userID.String(), Limit: MessageUserLimit},
+		{Key: "message:room:" + roomID.String(), Limit: MessageRoomLimit},
+	}
+}
+
+// MessageRequestBudgets бюджеты отправки сообщения или вложений через REST, комната берется из :id
+func MessageRequestBudgets(c *gin.Context) []ratelimit.Budget {
+	userID := c.MustGet(UserIDKey).(uuid.UUID)
+
+	roomID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil
+	}
+	return MessageBudgets(userID, roomID)
+}
+
+// RateLimit отклоняет запрос с 429, если исчерпан любой из бюджетов.
+// Выставляет X-RateLimit-* и Retry-After. Если Redis недоступен, запрос пропускается.
+func RateLimit(limiter *ratelimit.Limiter, budgets BudgetFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list := budgets(c)
+		if len(list) == 0 {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.AllowAll(c.Request.Context(), list)
+		if err != nil {
+			log.Printf("Rate limiter error: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(Seconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(Seconds(result.RetryAfter)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Seconds округляет длительность вверх до целых секунд для Retry-After
+func Seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
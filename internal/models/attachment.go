@@ -0,0 +1,17 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type MessageAttachment struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	MessageID    uuid.UUID `gorm:"not null;index"`
+	FileName     string    `gorm:"type:varchar(255);not null"`
+	FileSize     int64     `gorm:"not null"`
+	FileType     string    `gorm:"type:varchar(100)"`
+	FileURL      string    `gorm:"type:varchar(500);not null"`
+	ThumbnailURL string    `gorm:"type:varchar(500)"`
+	CreatedAt    time.Time
+}
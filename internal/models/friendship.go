@@ -0,0 +1,34 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Статусы дружбы
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+)
+
+// Friendship заявка в друзья от RequesterID к AddresseeID; после принятия — дружба.
+// На пару пользователей не больше одной записи в любом направлении.
+type Friendship struct {
+	RequesterID uuid.UUID `gorm:"type:uuid;primaryKey;check:check_not_self_friend,requester_id <> addressee_id"`
+	AddresseeID uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+	Status      string    `gorm:"type:varchar(20);not null;default:pending;check:status IN ('pending','accepted')"`
+	CreatedAt   time.Time
+	AcceptedAt  *time.Time
+
+	// Связи
+	Requester User `gorm:"foreignKey:RequesterID;constraint:OnDelete:CASCADE"`
+	Addressee User `gorm:"foreignKey:AddresseeID;constraint:OnDelete:CASCADE"`
+}
+
+// OtherUser возвращает участника пары, отличного от userID
+func (f *Friendship) OtherUser(userID uuid.UUID) User {
+	if f.RequesterID == userID {
+		return f.Addressee
+	}
+	return f.Requester
+}
@@ -2,11 +2,12 @@ package models
 
 import (
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"time"
 )
 
 type Message struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
 	RoomID    uuid.UUID `gorm:"not null"`
 	UserID    uuid.UUID `gorm:"not null"`
 	Content   string    `gorm:"not null"`
@@ -14,7 +15,25 @@ type Message struct {
 	CreatedAt time.Time
 	EditedAt  *time.Time
 
+	// Количество правок, прежние версии хранятся в message_revisions
+	RevisionCount int `gorm:"not null;default:0"`
+
+	// Мягкое удаление: в истории остается заглушка "сообщение удалено"
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	DeletedBy *uuid.UUID     `gorm:"type:uuid"`
+
+	// Ответ на сообщение (цитата)
+	ReplyToID *uuid.UUID `gorm:"type:uuid;index"`
+
+	// Корневое сообщение треда; у самого корня nil
+	ThreadID    *uuid.UUID `gorm:"type:uuid;index"`
+	ReplyCount  int        `gorm:"not null;default:0"`
+	LastReplyAt *time.Time
+
 	// Связи
-	User User `gorm:"foreignKey:UserID"`
-	Room Room `gorm:"foreignKey:RoomID"`
+	User        User                `gorm:"foreignKey:UserID"`
+	Room        Room                `gorm:"foreignKey:RoomID"`
+	ReplyTo     *Message            `gorm:"foreignKey:ReplyToID;constraint:OnDelete:SET NULL"`
+	Thread      *Message            `gorm:"foreignKey:ThreadID;constraint:OnDelete:CASCADE"`
+	Attachments []MessageAttachment `gorm:"foreignKey:MessageID;constraint:OnDelete:CASCADE"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Действия журнала модерации сообщений
+const (
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+)
+
+// MessageAuditLog запись журнала удалений и восстановлений сообщений.
+// Не ссылается на messages внешним ключом, чтобы переживать очистку сообщений.
+type MessageAuditLog struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	RoomID    uuid.UUID `gorm:"type:uuid;not null;index:idx_message_audit_room_created,priority:1"`
+	MessageID uuid.UUID `gorm:"type:uuid;not null;index"`
+	AuthorID  uuid.UUID `gorm:"type:uuid;not null"`
+	ActorID   uuid.UUID `gorm:"type:uuid;not null"`
+	Action    string    `gorm:"type:varchar(20);not null"`
+	CreatedAt time.Time `gorm:"index:idx_message_audit_room_created,priority:2,sort:desc"`
+
+	// Связи
+	Actor User `gorm:"foreignKey:ActorID;constraint:OnDelete:CASCADE"`
+}
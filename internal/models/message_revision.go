@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// MessageRevision прежний текст сообщения, сохраненный перед очередной правкой
+type MessageRevision struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	MessageID uuid.UUID `gorm:"type:uuid;not null;index:idx_message_revisions_message_created,priority:1"`
+	Content   string    `gorm:"not null"`
+	EditedBy  uuid.UUID `gorm:"type:uuid;not null"`
+	CreatedAt time.Time `gorm:"index:idx_message_revisions_message_created,priority:2"`
+
+	// Связи
+	Message Message `gorm:"foreignKey:MessageID;constraint:OnDelete:CASCADE"`
+}
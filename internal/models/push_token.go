@@ -0,0 +1,35 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Платформы push-уведомлений
+const (
+	PlatformWeb     = "web"
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+)
+
+// PushToken токен устройства для push-уведомлений. Для web это JSON подписки Web Push.
+type PushToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_push_tokens_user_token;index"`
+	Token     string    `gorm:"type:text;not null;uniqueIndex:idx_push_tokens_user_token"`
+	Platform  string    `gorm:"type:varchar(20);not null;check:platform IN ('web','ios','android')"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// Связи
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// IsValidPlatform проверяет, что платформа поддерживается
+func IsValidPlatform(platform string) bool {
+	switch platform {
+	case PlatformWeb, PlatformIOS, PlatformAndroid:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type MessageReaction struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	MessageID uuid.UUID `gorm:"not null;uniqueIndex:idx_reactions_unique"`
+	UserID    uuid.UUID `gorm:"not null;uniqueIndex:idx_reactions_unique"`
+	Emoji     string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_reactions_unique"`
+	CreatedAt time.Time
+
+	// Связи
+	Message Message `gorm:"foreignKey:MessageID;constraint:OnDelete:CASCADE"`
+	User    User    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
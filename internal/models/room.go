@@ -6,12 +6,16 @@ import (
 )
 
 type Room struct {
-	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey"`
 	Name       string    `gorm:"not null"`
 	Type       string    `gorm:"not null;check:type IN ('direct','group')"`
 	MaxMembers int       `gorm:"default:20"`
-	CreatedBy  uuid.UUID
-	CreatedAt  time.Time
+
+	// В приватную группу вступают только по приглашению
+	IsPrivate bool `gorm:"not null;default:false"`
+
+	CreatedBy uuid.UUID
+	CreatedAt time.Time
 
 	// Связи
 	Members  []User    `gorm:"many2many:room_members"`
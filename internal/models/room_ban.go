@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type RoomBan struct {
+	RoomID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	BannedBy  uuid.UUID `gorm:"type:uuid;not null"`
+	Reason    string    `gorm:"type:varchar(500)"`
+	CreatedAt time.Time
+
+	// Связи
+	Room Room `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE"`
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
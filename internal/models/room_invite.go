@@ -0,0 +1,33 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// RoomInvite код приглашения в групповую комнату. MaxUses 0 и ExpiresAt nil — без ограничений.
+type RoomInvite struct {
+	Code      string    `gorm:"type:varchar(16);primaryKey"`
+	RoomID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	CreatedBy uuid.UUID `gorm:"type:uuid;not null"`
+	MaxUses   int       `gorm:"not null;default:0;check:max_uses >= 0"`
+	Uses      int       `gorm:"not null;default:0"`
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+
+	// Связи
+	Room    Room `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE"`
+	Creator User `gorm:"foreignKey:CreatedBy;constraint:OnDelete:CASCADE"`
+}
+
+// IsUsable сообщает, можно ли вступить по приглашению в момент now
+func (i *RoomInvite) IsUsable(now time.Time) bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !i.ExpiresAt.After(now) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
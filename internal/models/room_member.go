@@ -0,0 +1,53 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Роли участников комнаты
+const (
+	RoleMember    = "member"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleMember:    1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// RoomMember join-таблица room_members с данными участника
+type RoomMember struct {
+	UserID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	RoomID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	JoinedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Role       string    `gorm:"type:varchar(20);default:'member';check:role IN ('member','moderator','admin')"`
+	LastReadAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+
+	// Отключенные уведомления; MutedUntil nil при Muted означает бессрочно
+	Muted      bool `gorm:"not null;default:false"`
+	MutedUntil *time.Time
+}
+
+// IsMuted сообщает, отключены ли уведомления комнаты в момент now
+func (m *RoomMember) IsMuted(now time.Time) bool {
+	return m.Muted && (m.MutedUntil == nil || m.MutedUntil.After(now))
+}
+
+// IsValidRole проверяет, что роль существует
+func IsValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// HasRole проверяет, что роль не ниже требуемой
+func HasRole(role, required string) bool {
+	return roleRank[role] >= roleRank[required]
+}
+
+// RoleOutranks проверяет, что роль строго выше другой
+func RoleOutranks(role, other string) bool {
+	return roleRank[role] > roleRank[other]
+}
@@ -6,12 +6,15 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
 	Username     string    `gorm:"uniqueIndex;not null"`
 	Email        string    `gorm:"uniqueIndex;not null"`
 	PasswordHash string    `gorm:"not null"`
 	AvatarURL    string
-	Rooms        []Room
+	Rooms        []Room `gorm:"many2many:room_members"`
 	LastSeenAt   time.Time
 	CreatedAt    time.Time
+
+	// Открыть личную комнату с пользователем могут только его друзья
+	FriendsOnlyDirect bool `gorm:"not null;default:false"`
 }
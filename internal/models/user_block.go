@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type UserBlock struct {
+	BlockerID uuid.UUID `gorm:"type:uuid;primaryKey;check:check_not_self_block,blocker_id <> blocked_id"`
+	BlockedID uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+	CreatedAt time.Time
+
+	// Связи
+	Blocker User `gorm:"foreignKey:BlockerID;constraint:OnDelete:CASCADE"`
+	Blocked User `gorm:"foreignKey:BlockedID;constraint:OnDelete:CASCADE"`
+}
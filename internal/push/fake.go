@@ -0,0 +1,54 @@
+package push
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// SentNotification уведомление, принятое FakeProvider
+type SentNotification struct {
+	Token        string
+	Notification Notification
+}
+
+// FakeProvider провайдер для тестов и локального запуска: ничего не отправляет,
+// а запоминает уведомления. Токены из Invalid считаются недействительными.
+type FakeProvider struct {
+	mu      sync.Mutex
+	sent    []SentNotification
+	invalid map[string]bool
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{invalid: make(map[string]bool)}
+}
+
+func (p *FakeProvider) Send(ctx context.Context, token string, n Notification) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.invalid[token] {
+		return ErrInvalidToken
+	}
+
+	p.sent = append(p.sent, SentNotification{Token: token, Notification: n})
+	log.Printf("Push (fake) to %s: %s: %s", token, n.Title, n.Body)
+	return nil
+}
+
+// Invalidate помечает токен недействительным
+func (p *FakeProvider) Invalidate(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.invalid[token] = true
+}
+
+// Sent возвращает копию принятых уведомлений
+func (p *FakeProvider) Sent() []SentNotification {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]SentNotification(nil), p.sent...)
+}
@@ -0,0 +1,21 @@
+package push
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidToken провайдер сообщил, что токен больше не действует: его нужно удалить
+var ErrInvalidToken = errors.New("push token is no longer valid")
+
+// Notification уведомление, которое видит пользователь
+type Notification struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Provider доставляет уведомления на устройства одной платформы
+type Provider interface {
+	Send(ctx context.Context, token string, n Notification) error
+}
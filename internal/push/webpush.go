@@ -0,0 +1,265 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// Сколько push-сервис хранит уведомление для офлайн устройства
+	webPushTTL = 24 * time.Hour
+
+	// Срок подписи VAPID, не больше 24 часов по RFC 8292
+	vapidTokenTTL = 12 * time.Hour
+
+	// Размер записи aes128gcm: уведомление всегда помещается в одну запись
+	webPushRecordSize = 4096
+)
+
+// WebPushSubscription подписка браузера (PushSubscription.toJSON()), хранится как токен
+type WebPushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// ParseWebPushSubscription разбирает и проверяет токен web-платформы
+func ParseWebPushSubscription(token string) (*WebPushSubscription, error) {
+	var sub WebPushSubscription
+	if err := json.Unmarshal([]byte(token), &sub); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, errors.New("subscription endpoint must be an https URL")
+	}
+	if sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+		return nil, errors.New("subscription keys are required")
+	}
+	return &sub, nil
+}
+
+// WebPushProvider отправляет уведомления по протоколу Web Push (RFC 8030)
+// с шифрованием aes128gcm (RFC 8291) и аутентификацией VAPID (RFC 8292)
+type WebPushProvider struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  string
+	subject    string
+	client     *http.Client
+}
+
+// NewWebPushProvider создает провайдер из пары VAPID ключей в base64url:
+// публичный ключ — несжатая точка P-256, приватный — 32 байта скаляра.
+// subject — контакт для push-сервиса, mailto: или https: URL.
+func NewWebPushProvider(publicKey, privateKey, subject string) (*WebPushProvider, error) {
+	d, err := decodeBase64URL(privateKey)
+	if err != nil || len(d) != 32 {
+		return nil, errors.New("invalid VAPID private key")
+	}
+
+	key := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	key.Curve = elliptic.P256()
+	key.X, key.Y = key.Curve.ScalarBaseMult(d)
+
+	pub, err := decodeBase64URL(publicKey)
+	if err != nil {
+		return nil, errors.New("invalid VAPID public key")
+	}
+	if !bytes.Equal(pub, elliptic.Marshal(key.Curve, key.X, key.Y)) {
+		return nil, errors.New("VAPID public key does not match private key")
+	}
+
+	return &WebPushProvider{
+		privateKey: key,
+		publicKey:  publicKey,
+		subject:    subject,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// PublicKey возвращает публичный VAPID ключ для PushManager.subscribe в браузере
+func (p *WebPushProvider) PublicKey() string {
+	return p.publicKey
+}
+
+func (p *WebPushProvider) Send(ctx context.Context, token string, n Notification) error {
+	sub, err := ParseWebPushSubscription(token)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	body, err := encryptWebPush(sub, payload)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	auth, err := p.vapidAuthorization(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(webPushTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", auth)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrInvalidToken
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("web push: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// vapidAuthorization подписывает JWT для origin push-сервиса
+func (p *WebPushProvider) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(vapidTokenTTL).Unix(),
+		"sub": p.subject,
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(p.privateKey)
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + signed + ", k=" + p.publicKey, nil
+}
+
+// encryptWebPush шифрует payload одной записью aes128gcm для ключей подписки
+func encryptWebPush(sub *WebPushSubscription, payload []byte) ([]byte, error) {
+	uaPublic, err := decodeBase64URL(sub.Keys.P256dh)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := decodeBase64URL(sub.Keys.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	curve := ecdh.P256()
+	uaKey, err := curve.NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Одноразовый ключ сервера на каждое сообщение
+	asKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	sharedSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, err := hkdfRead(sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	cek, err := hkdfRead(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdfRead(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0x02 отмечает последнюю (и единственную) запись
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	if len(plaintext)+gcm.Overhead() > webPushRecordSize {
+		return nil, errors.New("web push payload is too large")
+	}
+
+	// Заголовок: salt | rs | idlen | keyid (публичный ключ сервера)
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+func hkdfRead(secret, salt, info []byte, n int) ([]byte, error) {
+	out := make([]byte, n)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// decodeBase64URL принимает base64url с паддингом и без
+func decodeBase64URL(s string) ([]byte, error) {
+	if data, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return data, nil
+	}
+	return base64.URLEncoding.DecodeString(s)
+}
@@ -0,0 +1,162 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+)
+
+const (
+	// Очередь сообщений, ожидающих рассылки уведомлений
+	workerQueueSize = 1024
+
+	// Таймаут отправки одного уведомления
+	sendTimeout = 10 * time.Second
+
+	// Длина текста сообщения в уведомлении
+	maxBodyRunes = 200
+)
+
+// Repository данные, нужные для рассылки уведомлений
+type Repository interface {
+	GetRoom(id string) (*models.Room, error)
+	GetNotifiableMembers(roomID, senderID uuid.UUID) ([]uuid.UUID, error)
+	GetPushTokens(userIDs []uuid.UUID) ([]models.PushToken, error)
+	DeletePushTokensByID(ids []uuid.UUID) error
+}
+
+// Presence знает, у кого из пользователей есть живое WebSocket соединение
+type Presence interface {
+	OfflineUsers(userIDs []uuid.UUID) []uuid.UUID
+}
+
+// Worker в фоне рассылает push-уведомления о новых сообщениях участникам комнаты,
+// у которых нет открытого соединения
+type Worker struct {
+	repo      Repository
+	presence  Presence
+	providers map[string]Provider
+	queue     chan *models.Message
+}
+
+func NewWorker(repo Repository, presence Presence) *Worker {
+	return &Worker{
+		repo:      repo,
+		presence:  presence,
+		providers: make(map[string]Provider),
+		queue:     make(chan *models.Message, workerQueueSize),
+	}
+}
+
+// SetProvider задает провайдера платформы. Токены платформ без провайдера пропускаются.
+// Вызывается до Run.
+func (w *Worker) SetProvider(platform string, provider Provider) {
+	w.providers[platform] = provider
+}
+
+// NotifyMessage ставит сообщение в очередь на рассылку; при переполнении уведомление теряется
+func (w *Worker) NotifyMessage(message *models.Message) {
+	select {
+	case w.queue <- message:
+	default:
+		log.Printf("Push queue full, notification for message %s dropped", message.ID)
+	}
+}
+
+// Run обрабатывает очередь до отмены ctx
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-w.queue:
+			w.deliver(ctx, message)
+		}
+	}
+}
+
+func (w *Worker) deliver(ctx context.Context, message *models.Message) {
+	members, err := w.repo.GetNotifiableMembers(message.RoomID, message.UserID)
+	if err != nil {
+		log.Printf("Push members error: %v", err)
+		return
+	}
+
+	offline := w.presence.OfflineUsers(members)
+	if len(offline) == 0 {
+		return
+	}
+
+	tokens, err := w.repo.GetPushTokens(offline)
+	if err != nil {
+		log.Printf("Push tokens error: %v", err)
+		return
+	}
+	if len(tokens) == 0 {
+		return
+	}
+
+	room, err := w.repo.GetRoom(message.RoomID.String())
+	if err != nil {
+		log.Printf("Push room error: %v", err)
+		return
+	}
+	notification := newMessageNotification(room, message)
+
+	invalid := make([]uuid.UUID, 0)
+	for _, token := range tokens {
+		provider, ok := w.providers[token.Platform]
+		if !ok {
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := provider.Send(sendCtx, token.Token, notification)
+		cancel()
+
+		if errors.Is(err, ErrInvalidToken) {
+			invalid = append(invalid, token.ID)
+		} else if err != nil {
+			log.Printf("Push send error (%s): %v", token.Platform, err)
+		}
+	}
+
+	if err := w.repo.DeletePushTokensByID(invalid); err != nil {
+		log.Printf("Push token prune error: %v", err)
+	}
+}
+
+// newMessageNotification: в личной комнате заголовок — автор, в групповой — название комнаты
+func newMessageNotification(room *models.Room, message *models.Message) Notification {
+	body := truncateRunes(message.Content, maxBodyRunes)
+	if body == "" && len(message.Attachments) > 0 {
+		// Сообщение только из вложений показываем по имени первого файла
+		body = "📎 " + message.Attachments[0].FileName
+	}
+	title := message.User.Username
+	if room.Type != "direct" {
+		title = room.Name
+		body = message.User.Username + ": " + body
+	}
+
+	return Notification{
+		Title: title,
+		Body:  body,
+		Data: map[string]string{
+			"room_id":    message.RoomID.String(),
+			"message_id": message.ID.String(),
+		},
+	}
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,166 @@
+package push
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/database"
+	"github.com/thereayou/discord-lite/internal/models"
+)
+
+// onlinePresence считает подключенными только пользователей из набора
+type onlinePresence map[uuid.UUID]bool
+
+func (p onlinePresence) OfflineUsers(userIDs []uuid.UUID) []uuid.UUID {
+	offline := make([]uuid.UUID, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if !p[userID] {
+			offline = append(offline, userID)
+		}
+	}
+	return offline
+}
+
+type workerFixture struct {
+	db       *database.Database
+	provider *FakeProvider
+	worker   *Worker
+	online   onlinePresence
+	roomID   uuid.UUID
+	sender   models.User
+}
+
+// newWorkerFixture создает групповую комнату с автором сообщений и участниками members
+func newWorkerFixture(t *testing.T, members ...string) (*workerFixture, map[string]uuid.UUID) {
+	t.Helper()
+
+	db, err := database.OpenSQLite("file::memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+
+	ids := make(map[string]uuid.UUID)
+	for _, name := range append([]string{"alice"}, members...) {
+		user := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+		if err := db.SaveUser(user); err != nil {
+			t.Fatalf("SaveUser: %v", err)
+		}
+		ids[name] = user.ID
+	}
+
+	room := &models.Room{Name: "general", Type: "group", CreatedBy: ids["alice"], CreatedAt: time.Now()}
+	if err := db.CreateRoom(room); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	for _, userID := range ids {
+		if err := db.AddUserToRoom(userID.String(), room.ID.String()); err != nil {
+			t.Fatalf("AddUserToRoom: %v", err)
+		}
+	}
+
+	online := make(onlinePresence)
+	provider := NewFakeProvider()
+	worker := NewWorker(db, online)
+	worker.SetProvider(models.PlatformWeb, provider)
+
+	return &workerFixture{
+		db:       db,
+		provider: provider,
+		worker:   worker,
+		online:   online,
+		roomID:   room.ID,
+		sender:   models.User{ID: ids["alice"], Username: "alice"},
+	}, ids
+}
+
+func (f *workerFixture) addToken(t *testing.T, userID uuid.UUID, token string) {
+	t.Helper()
+
+	if err := f.db.SavePushToken(&models.PushToken{UserID: userID, Token: token, Platform: models.PlatformWeb}); err != nil {
+		t.Fatalf("SavePushToken: %v", err)
+	}
+}
+
+// deliver рассылает уведомления о сообщении alice синхронно
+func (f *workerFixture) deliver(text string) {
+	f.worker.deliver(context.Background(), &models.Message{
+		ID:      uuid.New(),
+		RoomID:  f.roomID,
+		UserID:  f.sender.ID,
+		Content: text,
+		User:    f.sender,
+	})
+}
+
+func sentTokens(provider *FakeProvider) []string {
+	tokens := make([]string, 0)
+	for _, sent := range provider.Sent() {
+		tokens = append(tokens, sent.Token)
+	}
+	return tokens
+}
+
+func TestDeliverNotifiesOnlyOfflineMembers(t *testing.T) {
+	f, ids := newWorkerFixture(t, "bob", "carol")
+	f.addToken(t, ids["alice"], "alice-device")
+	f.addToken(t, ids["bob"], "bob-device")
+	f.addToken(t, ids["carol"], "carol-device")
+	f.online[ids["carol"]] = true
+
+	f.deliver("hello")
+
+	sent := f.provider.Sent()
+	if len(sent) != 1 || sent[0].Token != "bob-device" {
+		t.Fatalf("expected a push to bob only, got %v", sentTokens(f.provider))
+	}
+	if sent[0].Notification.Title != "general" || sent[0].Notification.Body != "alice: hello" {
+		t.Fatalf("unexpected notification %+v", sent[0].Notification)
+	}
+	if sent[0].Notification.Data["room_id"] != f.roomID.String() {
+		t.Fatalf("notification must reference the room, got %v", sent[0].Notification.Data)
+	}
+}
+
+func TestDeliverSkipsMutedRoom(t *testing.T) {
+	f, ids := newWorkerFixture(t, "bob", "carol")
+	f.addToken(t, ids["bob"], "bob-device")
+	f.addToken(t, ids["carol"], "carol-device")
+
+	if err := f.db.SetRoomMute(ids["bob"], f.roomID, true, nil); err != nil {
+		t.Fatalf("SetRoomMute: %v", err)
+	}
+	// Истекшее отключение уже не действует
+	expired := time.Now().Add(-time.Minute)
+	if err := f.db.SetRoomMute(ids["carol"], f.roomID, true, &expired); err != nil {
+		t.Fatalf("SetRoomMute: %v", err)
+	}
+
+	f.deliver("hello")
+
+	if tokens := sentTokens(f.provider); len(tokens) != 1 || tokens[0] != "carol-device" {
+		t.Fatalf("muted member must be skipped, sent to %v", tokens)
+	}
+}
+
+func TestDeliverPrunesInvalidTokens(t *testing.T) {
+	f, ids := newWorkerFixture(t, "bob")
+	f.addToken(t, ids["bob"], "stale-device")
+	f.addToken(t, ids["bob"], "bob-device")
+	f.provider.Invalidate("stale-device")
+
+	f.deliver("hello")
+
+	if tokens := sentTokens(f.provider); len(tokens) != 1 || tokens[0] != "bob-device" {
+		t.Fatalf("expected a push to the valid token only, got %v", tokens)
+	}
+
+	tokens, err := f.db.GetPushTokens([]uuid.UUID{ids["bob"]})
+	if err != nil {
+		t.Fatalf("GetPushTokens: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].Token != "bob-device" {
+		t.Fatalf("invalid token must be pruned, left %d tokens", len(tokens))
+	}
+}
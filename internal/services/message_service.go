@@ -0,0 +1,418 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/dto"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/websocket"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMessageNotFound      = errors.New("message not found")
+	ErrNotRoomMember        = errors.New("you are not a member of this room")
+	ErrEmptyContent         = errors.New("content is required")
+	ErrContentTooLong       = errors.New("content is too long")
+	ErrMissingAttachments   = errors.New("image and file messages require attachments")
+	ErrInvalidMessageType   = errors.New("invalid message type")
+	ErrInvalidReplyTo       = errors.New("reply target not found in this room")
+	ErrInvalidThread        = errors.New("thread not found in this room")
+	ErrCannotEditMessage    = errors.New("you can only edit your own messages")
+	ErrCannotDeleteMessage  = errors.New("you can only delete your own messages")
+	ErrCannotRestoreMessage = errors.New("only room admins can restore messages")
+	ErrMessagePurged        = errors.New("message content has already been purged")
+)
+
+// Максимальная длина текста сообщения в символах
+const maxMessageContentLength = 4000
+
+// Типы сообщений, которые могут отправлять клиенты; system создает только сервер.
+// Значение — требуются ли вложения.
+var clientMessageTypes = map[string]bool{
+	"text":  false,
+	"image": true,
+	"file":  true,
+}
+
+// MessageRepository хранилище, через которое сервис читает и пишет сообщения
+type MessageRepository interface {
+	GetUser(id string) (*models.User, error)
+	UpdateLastSeen(id string) error
+	IsRoomMember(userID, roomID string) (bool, error)
+	GetMemberRole(userID, roomID uuid.UUID) (string, error)
+
+	GetMessage(id string) (*models.Message, error)
+	GetDeletedMessage(id string) (*models.Message, error)
+	SaveMessage(message *models.Message) error
+	SaveMessageWithAttachments(message *models.Message, attachments []models.MessageAttachment) error
+	EditMessage(message *models.Message, content string, editorID uuid.UUID) error
+	DeleteMessage(message *models.Message, actorID uuid.UUID) error
+	RestoreMessage(message *models.Message, actorID uuid.UUID) error
+}
+
+// MessageBroadcaster доставляет события подключенным клиентам
+type MessageBroadcaster interface {
+	SendToRoom(roomID uuid.UUID, message []byte)
+	SendToRoomFrom(roomID, senderID uuid.UUID, message []byte)
+	SendToThread(roomID, threadID, senderID uuid.UUID, message []byte)
+}
+
+// Notifier оповещает офлайн участников о новом сообщении (push-уведомления)
+type Notifier interface {
+	NotifyMessage(message *models.Message)
+}
+
+// SendMessageInput новое сообщение от пользователя
+type SendMessageInput struct {
+	RoomID    uuid.UUID
+	UserID    uuid.UUID
+	Content   string
+	Type      string
+	ReplyToID *uuid.UUID
+	ThreadID  *uuid.UUID
+
+	// Уже сохраненные в хранилище файлы; с ними текст не обязателен
+	Attachments []models.MessageAttachment
+}
+
+// MessageService единая логика отправки, правки и удаления сообщений
+// для REST и WebSocket: проверка прав, сохранение и рассылка событий
+type MessageService struct {
+	repo        MessageRepository
+	broadcaster MessageBroadcaster
+	notifier    Notifier
+}
+
+func NewMessageService(repo MessageRepository, broadcaster MessageBroadcaster) *MessageService {
+	return &MessageService{repo: repo, broadcaster: broadcaster}
+}
+
+// SetNotifier включает уведомления о новых сообщениях. Вызывается до обработки запросов.
+func (s *MessageService) SetNotifier(notifier Notifier) {
+	s.notifier = notifier
+}
+
+// Send проверяет и сохраняет сообщение, затем рассылает его комнате или подписчикам треда
+func (s *MessageService) Send(in SendMessageInput) (*models.Message, error) {
+	if in.Content == "" && len(in.Attachments) == 0 {
+		return nil, ErrEmptyContent
+	}
+	if utf8.RuneCountInString(in.Content) > maxMessageContentLength {
+		return nil, ErrContentTooLong
+	}
+
+	msgType := "text"
+	if in.Type != "" {
+		msgType = in.Type
+	}
+	needsAttachments, ok := clientMessageTypes[msgType]
+	if !ok {
+		return nil, ErrInvalidMessageType
+	}
+	if needsAttachments && len(in.Attachments) == 0 {
+		return nil, ErrMissingAttachments
+	}
+
+	if err := s.requireMember(in.UserID, in.RoomID); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateRefs(in.RoomID, in.ReplyToID, in.ThreadID); err != nil {
+		return nil, err
+	}
+
+	message := &models.Message{
+		RoomID:    in.RoomID,
+		UserID:    in.UserID,
+		Content:   in.Content,
+		Type:      msgType,
+		ReplyToID: in.ReplyToID,
+		ThreadID:  in.ThreadID,
+		CreatedAt: time.Now(),
+	}
+
+	var err error
+	if len(in.Attachments) > 0 {
+		err = s.repo.SaveMessageWithAttachments(message, in.Attachments)
+	} else {
+		err = s.repo.SaveMessage(message)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Перечитываем, чтобы получить цитату и вложения в том же виде, что и в истории
+	saved, err := s.loadWithAuthor(message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(websocket.TypeMessage, saved, saved.UserID, saved.UserID, dto.NewMessageResponse(saved, &saved.User))
+	if saved.ThreadID != nil {
+		s.notifyThreadUpdated(*saved.ThreadID, saved.UserID)
+	}
+	if s.notifier != nil {
+		s.notifier.NotifyMessage(saved)
+	}
+
+	go func() {
+		if err := s.repo.UpdateLastSeen(in.UserID.String()); err != nil {
+			log.Printf("Failed to update last seen: %v", err)
+		}
+	}()
+
+	return saved, nil
+}
+
+// Edit меняет текст сообщения автора; правка без изменений ничего не рассылает
+func (s *MessageService) Edit(messageID, editorID uuid.UUID, content string) (*models.Message, error) {
+	message, err := s.getMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Исключенный из комнаты автор больше не может править свои сообщения
+	if err := s.requireMember(editorID, message.RoomID); err != nil {
+		return nil, err
+	}
+
+	if message.UserID != editorID {
+		return nil, ErrCannotEditMessage
+	}
+
+	if content == "" {
+		return nil, ErrEmptyContent
+	}
+	if utf8.RuneCountInString(content) > maxMessageContentLength {
+		return nil, ErrContentTooLong
+	}
+
+	if content == message.Content {
+		return message, nil
+	}
+
+	if err := s.repo.EditMessage(message, content, editorID); err != nil {
+		return nil, err
+	}
+
+	s.publish(websocket.TypeMessageEdit, message, editorID, message.UserID, map[string]interface{}{
+		"message_id":     message.ID,
+		"content":        message.Content,
+		"edited_at":      message.EditedAt,
+		"revision_count": message.RevisionCount,
+	})
+
+	return message, nil
+}
+
+// Delete мягко удаляет сообщение; чужие сообщения могут удалять модераторы и администраторы
+func (s *MessageService) Delete(messageID, actorID uuid.UUID) (*models.Message, error) {
+	message, err := s.getMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireMember(actorID, message.RoomID); err != nil {
+		return nil, err
+	}
+
+	if message.UserID != actorID && !s.hasRole(actorID, message.RoomID, models.RoleModerator) {
+		return nil, ErrCannotDeleteMessage
+	}
+
+	if err := s.repo.DeleteMessage(message, actorID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	s.publish(websocket.TypeMessageDelete, message, actorID, uuid.Nil, map[string]interface{}{
+		"message_id": message.ID,
+		"deleted_by": actorID,
+		"deleted_at": message.DeletedAt.Time,
+	})
+	if message.ThreadID != nil {
+		s.notifyThreadUpdated(*message.ThreadID, actorID)
+	}
+
+	return message, nil
+}
+
+// Restore восстанавливает удаленное сообщение (только admin комнаты)
+func (s *MessageService) Restore(messageID, actorID uuid.UUID) (*models.Message, error) {
+	message, err := s.repo.GetDeletedMessage(messageID.String())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	if !s.hasRole(actorID, message.RoomID, models.RoleAdmin) {
+		return nil, ErrCannotRestoreMessage
+	}
+
+	if message.Content == "" {
+		return nil, ErrMessagePurged
+	}
+
+	if err := s.repo.RestoreMessage(message, actorID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	restored, err := s.loadWithAuthor(message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(websocket.TypeMessageRestored, restored, actorID, restored.UserID, dto.NewMessageResponse(restored, &restored.User))
+	if restored.ThreadID != nil {
+		s.notifyThreadUpdated(*restored.ThreadID, actorID)
+	}
+
+	return restored, nil
+}
+
+// validateRefs проверяет ссылки нового сообщения: корень треда должен быть
+// сообщением основной ленты этой комнаты, а цитируемое сообщение — из той же ленты или треда
+func (s *MessageService) validateRefs(roomID uuid.UUID, replyToID, threadID *uuid.UUID) error {
+	if threadID != nil {
+		root, err := s.repo.GetMessage(threadID.String())
+		if err != nil || root.RoomID != roomID || root.ThreadID != nil {
+			return ErrInvalidThread
+		}
+	}
+
+	if replyToID != nil {
+		target, err := s.repo.GetMessage(replyToID.String())
+		if err != nil || target.RoomID != roomID {
+			return ErrInvalidReplyTo
+		}
+
+		if threadID == nil && target.ThreadID != nil {
+			return ErrInvalidReplyTo
+		}
+		if threadID != nil && target.ID != *threadID && (target.ThreadID == nil || *target.ThreadID != *threadID) {
+			return ErrInvalidReplyTo
+		}
+	}
+
+	return nil
+}
+
+// requireMember проверяет, что пользователь состоит в комнате
+func (s *MessageService) requireMember(userID, roomID uuid.UUID) error {
+	isMember, err := s.repo.IsRoomMember(userID.String(), roomID.String())
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return ErrNotRoomMember
+	}
+	return nil
+}
+
+func (s *MessageService) getMessage(id uuid.UUID) (*models.Message, error) {
+	message, err := s.repo.GetMessage(id.String())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	return message, nil
+}
+
+// loadWithAuthor загружает сообщение вместе с автором и автором цитаты
+func (s *MessageService) loadWithAuthor(id uuid.UUID) (*models.Message, error) {
+	message, err := s.getMessage(id)
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := s.repo.GetUser(message.UserID.String())
+	if err != nil {
+		return nil, err
+	}
+	message.User = *author
+
+	return message, nil
+}
+
+func (s *MessageService) hasRole(userID, roomID uuid.UUID, required string) bool {
+	role, err := s.repo.GetMemberRole(userID, roomID)
+	if err != nil {
+		return false
+	}
+	return models.HasRole(role, required)
+}
+
+// publish рассылает событие о сообщении: ответы в треде получают только подписчики треда.
+// senderID задает автора для фильтрации блокировок, uuid.Nil — без фильтрации.
+func (s *MessageService) publish(msgType websocket.MessageType, message *models.Message, actorID, senderID uuid.UUID, data interface{}) {
+	msgData, ok := encodeEvent(msgType, message.RoomID, message.ThreadID, actorID, data)
+	if !ok {
+		return
+	}
+
+	switch {
+	case message.ThreadID != nil:
+		s.broadcaster.SendToThread(message.RoomID, *message.ThreadID, senderID, msgData)
+	case senderID != uuid.Nil:
+		s.broadcaster.SendToRoomFrom(message.RoomID, senderID, msgData)
+	default:
+		s.broadcaster.SendToRoom(message.RoomID, msgData)
+	}
+}
+
+// notifyThreadUpdated рассылает в комнату новый счетчик ответов треда
+func (s *MessageService) notifyThreadUpdated(threadID, actorID uuid.UUID) {
+	root, err := s.repo.GetMessage(threadID.String())
+	if err != nil {
+		return
+	}
+
+	msgData, ok := encodeEvent(websocket.TypeThreadUpdated, root.RoomID, &root.ID, actorID, dto.ThreadUpdate{
+		ThreadID:    root.ID,
+		ReplyCount:  root.ReplyCount,
+		LastReplyAt: root.LastReplyAt,
+	})
+	if !ok {
+		return
+	}
+
+	s.broadcaster.SendToRoomFrom(root.RoomID, actorID, msgData)
+}
+
+func encodeEvent(msgType websocket.MessageType, roomID uuid.UUID, threadID *uuid.UUID, actorID uuid.UUID, data interface{}) ([]byte, bool) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", msgType, err)
+		return nil, false
+	}
+
+	msgData, err := json.Marshal(websocket.Message{
+		Type:      msgType,
+		RoomID:    &roomID,
+		ThreadID:  threadID,
+		UserID:    actorID,
+		Data:      payload,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", msgType, err)
+		return nil, false
+	}
+
+	return msgData, true
+}
@@ -0,0 +1,487 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thereayou/discord-lite/internal/models"
+	"github.com/thereayou/discord-lite/internal/websocket"
+	"gorm.io/gorm"
+)
+
+// memoryRepo хранилище сообщений в памяти для тестов сервиса
+type memoryRepo struct {
+	mu       sync.Mutex
+	users    map[uuid.UUID]*models.User
+	roles    map[uuid.UUID]map[uuid.UUID]string // roomID -> userID -> role
+	messages map[uuid.UUID]*models.Message
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{
+		users:    make(map[uuid.UUID]*models.User),
+		roles:    make(map[uuid.UUID]map[uuid.UUID]string),
+		messages: make(map[uuid.UUID]*models.Message),
+	}
+}
+
+func (r *memoryRepo) addMember(roomID uuid.UUID, role string) uuid.UUID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID := uuid.New()
+	r.users[userID] = &models.User{ID: userID, Username: "user-" + userID.String()[:8]}
+	if r.roles[roomID] == nil {
+		r.roles[roomID] = make(map[uuid.UUID]string)
+	}
+	r.roles[roomID][userID] = role
+	return userID
+}
+
+func (r *memoryRepo) removeMember(roomID, userID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.roles[roomID], userID)
+}
+
+func (r *memoryRepo) GetUser(id string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[uuid.MustParse(id)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *memoryRepo) UpdateLastSeen(id string) error {
+	return nil
+}
+
+func (r *memoryRepo) IsRoomMember(userID, roomID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.roles[uuid.MustParse(roomID)][uuid.MustParse(userID)]
+	return ok, nil
+}
+
+func (r *memoryRepo) GetMemberRole(userID, roomID uuid.UUID) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	role, ok := r.roles[roomID][userID]
+	if !ok {
+		return "", gorm.ErrRecordNotFound
+	}
+	return role, nil
+}
+
+func (r *memoryRepo) GetMessage(id string) (*models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message, ok := r.messages[uuid.MustParse(id)]
+	if !ok || message.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *message
+	return &copied, nil
+}
+
+func (r *memoryRepo) GetDeletedMessage(id string) (*models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message, ok := r.messages[uuid.MustParse(id)]
+	if !ok || !message.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *message
+	return &copied, nil
+}
+
+func (r *memoryRepo) SaveMessage(message *models.Message) error {
+	return r.SaveMessageWithAttachments(message, nil)
+}
+
+func (r *memoryRepo) SaveMessageWithAttachments(message *models.Message, attachments []models.MessageAttachment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if message.ID == uuid.Nil {
+		message.ID = uuid.New()
+	}
+	for i := range attachments {
+		attachments[i].MessageID = message.ID
+	}
+	message.Attachments = attachments
+
+	if message.ThreadID != nil {
+		if root, ok := r.messages[*message.ThreadID]; ok {
+			root.ReplyCount++
+			root.LastReplyAt = &message.CreatedAt
+		}
+	}
+
+	copied := *message
+	r.messages[message.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepo) EditMessage(message *models.Message, content string, editorID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	message.Content = content
+	message.EditedAt = &now
+	message.RevisionCount++
+
+	copied := *message
+	r.messages[message.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepo) DeleteMessage(message *models.Message, actorID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.messages[message.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+
+	message.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	message.DeletedBy = &actorID
+	stored.DeletedAt = message.DeletedAt
+	stored.DeletedBy = message.DeletedBy
+	return nil
+}
+
+func (r *memoryRepo) RestoreMessage(message *models.Message, actorID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.messages[message.ID]
+	if !ok || !stored.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+
+	stored.DeletedAt = gorm.DeletedAt{}
+	stored.DeletedBy = nil
+	return nil
+}
+
+// sentEvent событие, переданное рассыльщику
+type sentEvent struct {
+	target   string // room, room_from или thread
+	targetID uuid.UUID
+	senderID uuid.UUID
+	message  websocket.Message
+}
+
+type recordingBroadcaster struct {
+	mu     sync.Mutex
+	events []sentEvent
+}
+
+func (b *recordingBroadcaster) record(target string, targetID, senderID uuid.UUID, data []byte) {
+	var msg websocket.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		panic(err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, sentEvent{target: target, targetID: targetID, senderID: senderID, message: msg})
+}
+
+func (b *recordingBroadcaster) SendToRoom(roomID uuid.UUID, message []byte) {
+	b.record("room", roomID, uuid.Nil, message)
+}
+
+func (b *recordingBroadcaster) SendToRoomFrom(roomID, senderID uuid.UUID, message []byte) {
+	b.record("room_from", roomID, senderID, message)
+}
+
+func (b *recordingBroadcaster) SendToThread(roomID, threadID, senderID uuid.UUID, message []byte) {
+	b.record("thread", threadID, senderID, message)
+}
+
+func (b *recordingBroadcaster) sent() []sentEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]sentEvent(nil), b.events...)
+}
+
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []uuid.UUID
+}
+
+func (n *recordingNotifier) NotifyMessage(message *models.Message) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, message.ID)
+}
+
+type serviceFixture struct {
+	repo        *memoryRepo
+	broadcaster *recordingBroadcaster
+	notifier    *recordingNotifier
+	service     *MessageService
+	roomID      uuid.UUID
+}
+
+func newServiceFixture() *serviceFixture {
+	f := &serviceFixture{
+		repo:        newMemoryRepo(),
+		broadcaster: &recordingBroadcaster{},
+		notifier:    &recordingNotifier{},
+		roomID:      uuid.New(),
+	}
+	f.service = NewMessageService(f.repo, f.broadcaster)
+	f.service.SetNotifier(f.notifier)
+	return f
+}
+
+func (f *serviceFixture) send(t *testing.T, userID uuid.UUID, content string) *models.Message {
+	t.Helper()
+
+	message, err := f.service.Send(SendMessageInput{RoomID: f.roomID, UserID: userID, Content: content})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	return message
+}
+
+func TestSendBroadcastsAndNotifies(t *testing.T) {
+	f := newServiceFixture()
+	author := f.repo.addMember(f.roomID, models.RoleMember)
+
+	message := f.send(t, author, "hello")
+
+	if message.Type != "text" || message.User.ID != author {
+		t.Fatalf("unexpected saved message: type=%q author=%s", message.Type, message.User.ID)
+	}
+
+	events := f.broadcaster.sent()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	ev := events[0]
+	if ev.target != "room_from" || ev.targetID != f.roomID || ev.senderID != author {
+		t.Fatalf("unexpected delivery: %+v", ev)
+	}
+	if ev.message.Type != websocket.TypeMessage {
+		t.Fatalf("unexpected event type %q", ev.message.Type)
+	}
+
+	var payload struct {
+		ID      uuid.UUID `json:"id"`
+		Content string    `json:"content"`
+	}
+	if err := json.Unmarshal(ev.message.Data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != message.ID || payload.Content != "hello" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+
+	if len(f.notifier.messages) != 1 || f.notifier.messages[0] != message.ID {
+		t.Fatalf("expected push notification for %s, got %v", message.ID, f.notifier.messages)
+	}
+}
+
+func TestSendValidation(t *testing.T) {
+	f := newServiceFixture()
+	member := f.repo.addMember(f.roomID, models.RoleMember)
+	outsider := uuid.New()
+	attachment := []models.MessageAttachment{{FileName: "a.png", FileType: "image/png"}}
+
+	tests := []struct {
+		name string
+		in   SendMessageInput
+		want error
+	}{
+		{"empty content", SendMessageInput{UserID: member}, ErrEmptyContent},
+		{"too long", SendMessageInput{UserID: member, Content: strings.Repeat("я", maxMessageContentLength+1)}, ErrContentTooLong},
+		{"system type", SendMessageInput{UserID: member, Content: "x", Type: "system"}, ErrInvalidMessageType},
+		{"image without attachments", SendMessageInput{UserID: member, Content: "x", Type: "image"}, ErrMissingAttachments},
+		{"file without attachments", SendMessageInput{UserID: member, Content: "x", Type: "file"}, ErrMissingAttachments},
+		{"not a member", SendMessageInput{UserID: outsider, Content: "x"}, ErrNotRoomMember},
+		{"unknown thread", SendMessageInput{UserID: member, Content: "x", ThreadID: ptr(uuid.New())}, ErrInvalidThread},
+		{"unknown reply", SendMessageInput{UserID: member, Content: "x", ReplyToID: ptr(uuid.New())}, ErrInvalidReplyTo},
+		{"image with attachments", SendMessageInput{UserID: member, Type: "image", Attachments: attachment}, nil},
+		{"max length", SendMessageInput{UserID: member, Content: strings.Repeat("я", maxMessageContentLength)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.in.RoomID = f.roomID
+			_, err := f.service.Send(tt.in)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSendThreadReplyGoesToThread(t *testing.T) {
+	f := newServiceFixture()
+	author := f.repo.addMember(f.roomID, models.RoleMember)
+	root := f.send(t, author, "root")
+
+	reply, err := f.service.Send(SendMessageInput{RoomID: f.roomID, UserID: author, Content: "reply", ThreadID: &root.ID})
+	if err != nil {
+		t.Fatalf("Send reply: %v", err)
+	}
+
+	events := f.broadcaster.sent()[1:]
+	if len(events) != 2 {
+		t.Fatalf("expected reply and thread update, got %d events", len(events))
+	}
+	if events[0].target != "thread" || events[0].targetID != root.ID || events[0].message.Type != websocket.TypeMessage {
+		t.Fatalf("reply must go to thread subscribers: %+v", events[0])
+	}
+	if events[1].target != "room_from" || events[1].message.Type != websocket.TypeThreadUpdated {
+		t.Fatalf("room must get thread update: %+v", events[1])
+	}
+	if reply.ThreadID == nil || *reply.ThreadID != root.ID {
+		t.Fatalf("reply is not in thread")
+	}
+}
+
+func TestEdit(t *testing.T) {
+	f := newServiceFixture()
+	author := f.repo.addMember(f.roomID, models.RoleMember)
+	other := f.repo.addMember(f.roomID, models.RoleAdmin)
+	message := f.send(t, author, "hello")
+	sent := len(f.broadcaster.sent())
+
+	if _, err := f.service.Edit(message.ID, other, "hijack"); !errors.Is(err, ErrCannotEditMessage) {
+		t.Fatalf("expected ErrCannotEditMessage, got %v", err)
+	}
+	if _, err := f.service.Edit(message.ID, author, ""); !errors.Is(err, ErrEmptyContent) {
+		t.Fatalf("expected ErrEmptyContent, got %v", err)
+	}
+	if _, err := f.service.Edit(message.ID, author, strings.Repeat("x", maxMessageContentLength+1)); !errors.Is(err, ErrContentTooLong) {
+		t.Fatalf("expected ErrContentTooLong, got %v", err)
+	}
+	if _, err := f.service.Edit(uuid.New(), author, "x"); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound, got %v", err)
+	}
+
+	// Правка без изменений ничего не рассылает
+	if _, err := f.service.Edit(message.ID, author, "hello"); err != nil {
+		t.Fatalf("Edit unchanged: %v", err)
+	}
+	if got := len(f.broadcaster.sent()); got != sent {
+		t.Fatalf("unchanged edit must not broadcast, got %d new events", got-sent)
+	}
+
+	edited, err := f.service.Edit(message.ID, author, "hello, world")
+	if err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	if edited.Content != "hello, world" || edited.EditedAt == nil || edited.RevisionCount != 1 {
+		t.Fatalf("unexpected edited message: %+v", edited)
+	}
+
+	events := f.broadcaster.sent()
+	if len(events) != sent+1 || events[sent].message.Type != websocket.TypeMessageEdit {
+		t.Fatalf("expected message_edit event, got %+v", events[sent:])
+	}
+}
+
+func TestEditRequiresMembership(t *testing.T) {
+	f := newServiceFixture()
+	author := f.repo.addMember(f.roomID, models.RoleMember)
+	message := f.send(t, author, "hello")
+
+	f.repo.removeMember(f.roomID, author)
+
+	if _, err := f.service.Edit(message.ID, author, "changed"); !errors.Is(err, ErrNotRoomMember) {
+		t.Fatalf("expected ErrNotRoomMember, got %v", err)
+	}
+
+	stored, _ := f.repo.GetMessage(message.ID.String())
+	if stored.Content != "hello" {
+		t.Fatalf("message changed after rejected edit: %q", stored.Content)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	f := newServiceFixture()
+	author := f.repo.addMember(f.roomID, models.RoleMember)
+	member := f.repo.addMember(f.roomID, models.RoleMember)
+	moderator := f.repo.addMember(f.roomID, models.RoleModerator)
+
+	own := f.send(t, author, "own")
+	foreign := f.send(t, author, "foreign")
+	sent := len(f.broadcaster.sent())
+
+	if _, err := f.service.Delete(foreign.ID, member); !errors.Is(err, ErrCannotDeleteMessage) {
+		t.Fatalf("expected ErrCannotDeleteMessage, got %v", err)
+	}
+
+	if _, err := f.service.Delete(own.ID, author); err != nil {
+		t.Fatalf("Delete own: %v", err)
+	}
+	deleted, err := f.service.Delete(foreign.ID, moderator)
+	if err != nil {
+		t.Fatalf("Delete by moderator: %v", err)
+	}
+	if deleted.DeletedBy == nil || *deleted.DeletedBy != moderator {
+		t.Fatalf("deleted_by not recorded")
+	}
+
+	if _, err := f.service.Delete(own.ID, author); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound on second delete, got %v", err)
+	}
+
+	events := f.broadcaster.sent()[sent:]
+	if len(events) != 2 {
+		t.Fatalf("expected 2 delete events, got %d", len(events))
+	}
+	for _, ev := range events {
+		// Удаление рассылается без фильтра блокировок, чтобы заглушку увидели все
+		if ev.message.Type != websocket.TypeMessageDelete || ev.target != "room" {
+			t.Fatalf("unexpected delete event: %+v", ev)
+		}
+	}
+}
+
+func TestDeleteRequiresMembership(t *testing.T) {
+	f := newServiceFixture()
+	author := f.repo.addMember(f.roomID, models.RoleMember)
+	moderator := f.repo.addMember(f.roomID, models.RoleModerator)
+	message := f.send(t, author, "hello")
+
+	f.repo.removeMember(f.roomID, author)
+	if _, err := f.service.Delete(message.ID, author); !errors.Is(err, ErrNotRoomMember) {
+		t.Fatalf("expected ErrNotRoomMember for removed author, got %v", err)
+	}
+
+	// Модератор другой комнаты не может удалять сообщения этой
+	outsider := f.repo.addMember(uuid.New(), models.RoleModerator)
+	if _, err := f.service.Delete(message.ID, outsider); !errors.Is(err, ErrNotRoomMember) {
+		t.Fatalf("expected ErrNotRoomMember for outsider, got %v", err)
+	}
+
+	if _, err := f.service.Delete(message.ID, moderator); err != nil {
+		t.Fatalf("Delete by moderator: %v", err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,263 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Сколько хранится список контактов пользователя
+	contactsCacheTTL = time.Minute
+
+	// События присутствия копятся и рассылаются пачкой раз в этот интервал.
+	// Переподключение внутри окна не порождает пару offline/online.
+	presenceFlushInterval = time.Second
+)
+
+// ContactSource находит контакты пользователя: тех, кому виден его статус.
+// Отношение симметричное: если A видит B, то и B видит A.
+type ContactSource interface {
+	GetContactIDs(userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+type contactsEntry struct {
+	contacts map[uuid.UUID]bool
+	expires  time.Time
+}
+
+// contactsCache кэш контактов пользователей
+type contactsCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]contactsEntry
+}
+
+func newContactsCache() *contactsCache {
+	return &contactsCache{entries: make(map[uuid.UUID]contactsEntry)}
+}
+
+func (c *contactsCache) get(userID uuid.UUID) (map[uuid.UUID]bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[userID]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, userID)
+		return nil, false
+	}
+	return entry.contacts, true
+}
+
+func (c *contactsCache) set(userID uuid.UUID, contacts map[uuid.UUID]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[userID] = contactsEntry{contacts: contacts, expires: time.Now().Add(contactsCacheTTL)}
+}
+
+// forget сбрасывает контакты пользователя и все записи, где он числится контактом
+func (c *contactsCache) forget(userIDs ...uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, userID := range userIDs {
+		delete(c.entries, userID)
+		for ownerID, entry := range c.entries {
+			if entry.contacts[userID] {
+				delete(c.entries, ownerID)
+			}
+		}
+	}
+}
+
+// pendingPresence события присутствия пользователя, накопленные за окно рассылки
+type pendingPresence struct {
+	// Первое и последнее событие подключения за окно
+	first MessageType
+	last  MessageType
+
+	// Последний user_status за окно
+	status []byte
+}
+
+// SetContactSource задает, кому рассылать статусы пользователей. Вызывается до Run.
+// Без него статус пользователя получают только его собственные соединения.
+func (h *Hub) SetContactSource(source ContactSource) {
+	h.contactSource = source
+}
+
+// InvalidateContacts сбрасывает кэш контактов пользователей на всех инстансах.
+// Вызывается, когда у пользователей появилась или пропала общая комната.
+func (h *Hub) InvalidateContacts(userIDs ...uuid.UUID) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	h.contacts.forget(userIDs...)
+
+	if h.cluster != nil {
+		h.cluster.publish(&clusterEnvelope{
+			Kind:    envelopeContacts,
+			UserIDs: userIDs,
+		})
+	}
+}
+
+// contactsOf возвращает контакты пользователя из кэша или источника
+func (h *Hub) contactsOf(userID uuid.UUID) map[uuid.UUID]bool {
+	if contacts, ok := h.contacts.get(userID); ok {
+		return contacts
+	}
+
+	contacts := make(map[uuid.UUID]bool)
+	if h.contactSource == nil {
+		return contacts
+	}
+
+	ids, err := h.contactSource.GetContactIDs(userID)
+	if err != nil {
+		log.Printf("Contacts lookup error: %v", err)
+		return contacts
+	}
+
+	for _, id := range ids {
+		contacts[id] = true
+	}
+	h.contacts.set(userID, contacts)
+	return contacts
+}
+
+// canSeePresence сообщает, виден ли viewerID статус userID. blocked — пользователи,
+// с которыми у viewerID есть блокировка в любую сторону: их статус всегда скрыт.
+func (h *Hub) canSeePresence(viewerID, userID uuid.UUID, blocked map[uuid.UUID]bool) bool {
+	if viewerID == userID {
+		return true
+	}
+	return !blocked[userID] && h.contactsOf(viewerID)[userID]
+}
+
+// queueConnection откладывает user_online/user_offline до ближайшей рассылки
+func (h *Hub) queueConnection(userID uuid.UUID, status MessageType) {
+	h.pendingMu.Lock()
+	defer h.pendingMu.Unlock()
+
+	p := h.pendingEntry(userID)
+	if p.first == "" {
+		p.first = status
+	}
+	p.last = status
+}
+
+// queueStatus откладывает user_status до ближайшей рассылки; более новый заменяет старый
+func (h *Hub) queueStatus(userID uuid.UUID, data []byte) {
+	h.pendingMu.Lock()
+	defer h.pendingMu.Unlock()
+
+	h.pendingEntry(userID).status = data
+}
+
+// pendingEntry вызывается под pendingMu
+func (h *Hub) pendingEntry(userID uuid.UUID) *pendingPresence {
+	p, ok := h.pending[userID]
+	if !ok {
+		p = &pendingPresence{}
+		h.pending[userID] = p
+	}
+	return p
+}
+
+// runPresenceFlush периодически рассылает накопленные события присутствия
+func (h *Hub) runPresenceFlush() {
+	ticker := time.NewTicker(presenceFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+			h.flushPresence()
+		}
+	}
+}
+
+type presenceFrame struct {
+	userID uuid.UUID
+	data   []byte
+}
+
+func (h *Hub) flushPresence() {
+	h.pendingMu.Lock()
+	pending := h.pending
+	h.pending = make(map[uuid.UUID]*pendingPresence)
+	h.pendingMu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	frames := make([]presenceFrame, 0, len(pending))
+	for userID, p := range pending {
+		// Разные первое и последнее событие — пользователь вернулся в исходное
+		// состояние внутри окна, и для остальных ничего не изменилось
+		connection := p.last
+		if p.first != p.last {
+			connection = ""
+		}
+
+		if connection != "" {
+			if data, err := connectionFrame(userID, connection); err == nil {
+				frames = append(frames, presenceFrame{userID: userID, data: data})
+			}
+		}
+		if p.status != nil && connection != TypeUserOffline {
+			frames = append(frames, presenceFrame{userID: userID, data: p.status})
+		}
+	}
+
+	// Контакты загружаются без блокировки hub
+	audiences := make(map[uuid.UUID]map[uuid.UUID]bool, len(pending))
+	for _, frame := range frames {
+		if _, ok := audiences[frame.userID]; !ok {
+			audiences[frame.userID] = h.contactsOf(frame.userID)
+		}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, frame := range frames {
+		h.deliverPresenceLocal(frame.userID, audiences[frame.userID], frame.data)
+	}
+}
+
+// deliverPresenceLocal отправляет событие присутствия userID его контактам и
+// его собственным соединениям на этом инстансе. Вызывающий должен держать h.mu.
+func (h *Hub) deliverPresenceLocal(userID uuid.UUID, contacts map[uuid.UUID]bool, data []byte) {
+	for _, client := range h.userClients[userID] {
+		client.enqueue(data)
+	}
+
+	for contactID := range contacts {
+		if contactID == userID || h.isBlockedEither(contactID, userID) {
+			continue
+		}
+		for _, client := range h.userClients[contactID] {
+			client.enqueue(data)
+		}
+	}
+}
+
+func connectionFrame(userID uuid.UUID, status MessageType) ([]byte, error) {
+	return json.Marshal(Message{
+		Type:      status,
+		UserID:    userID,
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// BlockSource загружает блокировки пользователей без соединения с этим инстансом
+type BlockSource interface {
+	GetBlockedIDs(userID uuid.UUID) ([]uuid.UUID, error)
+	GetBlockerIDs(userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+// SetBlockSource задает источник блокировок для REST запросов от неподключенных пользователей.
+// Вызывается до Run. Без него блокировки известны только для подключенных пользователей.
+func (h *Hub) SetBlockSource(source BlockSource) {
+	h.blockSource = source
+}
+
+// SetUserBlocks загружает блокировки пользователя перед регистрацией его соединения
+func (h *Hub) SetUserBlocks(userID uuid.UUID, blocked, blockedBy []uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.blocked[userID] = toSet(blocked)
+	h.blockedBy[userID] = toSet(blockedBy)
+}
+
+// UpdateBlock применяет блокировку (или разблокировку) blockerID -> blockedID на всех инстансах
+func (h *Hub) UpdateBlock(blockerID, blockedID uuid.UUID, blocked bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.updateBlockLocal(blockerID, blockedID, blocked)
+
+	if h.cluster != nil {
+		h.cluster.publish(&clusterEnvelope{
+			Kind:     envelopeBlock,
+			UserID:   &blockerID,
+			SenderID: &blockedID,
+			Blocked:  blocked,
+		})
+	}
+}
+
+// updateBlockLocal обновляет кэш только для подключенных к этому инстансу пользователей.
+// Вызывающий должен держать h.mu на запись.
+func (h *Hub) updateBlockLocal(blockerID, blockedID uuid.UUID, blocked bool) {
+	if set, ok := h.blocked[blockerID]; ok {
+		if blocked {
+			set[blockedID] = true
+		} else {
+			delete(set, blockedID)
+		}
+	}
+
+	if set, ok := h.blockedBy[blockedID]; ok {
+		if blocked {
+			set[blockerID] = true
+		} else {
+			delete(set, blockerID)
+		}
+	}
+}
+
+// isBlockedEither проверяет блокировку между подключенным viewerID и otherID в любую сторону
+func (h *Hub) isBlockedEither(viewerID, otherID uuid.UUID) bool {
+	return h.blocked[viewerID][otherID] || h.blockedBy[viewerID][otherID]
+}
+
+func toSet(ids []uuid.UUID) map[uuid.UUID]bool {
+	set := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// blockedEitherOf возвращает пользователей, с которыми у userID есть блокировка в любую сторону.
+// Для подключенных пользователей берется кэш хаба, для остальных — источник блокировок.
+func (h *Hub) blockedEitherOf(userID uuid.UUID) map[uuid.UUID]bool {
+	h.mu.RLock()
+	blocked, connected := h.blocked[userID]
+	if connected {
+		result := make(map[uuid.UUID]bool, len(blocked)+len(h.blockedBy[userID]))
+		for id := range blocked {
+			result[id] = true
+		}
+		for id := range h.blockedBy[userID] {
+			result[id] = true
+		}
+		h.mu.RUnlock()
+		return result
+	}
+	h.mu.RUnlock()
+
+	result := make(map[uuid.UUID]bool)
+	if h.blockSource == nil {
+		return result
+	}
+
+	blockedIDs, err := h.blockSource.GetBlockedIDs(userID)
+	if err != nil {
+		log.Printf("Blocks lookup error: %v", err)
+	}
+	blockerIDs, err := h.blockSource.GetBlockerIDs(userID)
+	if err != nil {
+		log.Printf("Blockers lookup error: %v", err)
+	}
+
+	for _, id := range append(blockedIDs, blockerIDs...) {
+		result[id] = true
+	}
+	return result
+}
@@ -23,25 +23,34 @@ const (
 	maxMessageSize = 512 * 1024 // 512KB
 )
 
+// Причина в close frame при остановке сервера: клиенту стоит переподключиться,
+// балансировщик отправит его на живой инстанс
+const shutdownCloseReason = "server restarting, reconnect"
+
 type ClientMessageHandler interface {
 	HandleMessage(client *Client, msg *Message) error
 }
 
-func NewClient(hub *Hub, conn *websocket.Conn, userID uuid.UUID) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, userID uuid.UUID, sessionID string) *Client {
 	return &Client{
-		ID:     uuid.New(),
-		UserID: userID,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-		Rooms:  make(map[uuid.UUID]bool),
-		Hub:    hub,
+		ID:        uuid.New(),
+		UserID:    userID,
+		SessionID: sessionID,
+		Conn:      conn,
+		Send:      make(chan []byte, sendQueueSize),
+		Rooms:     make(map[uuid.UUID]bool),
+		Hub:       hub,
+		Threads:   make(map[uuid.UUID]uuid.UUID),
+		done:      make(chan struct{}),
+
+		resuming: make(map[uuid.UUID][][]byte),
 	}
 }
 
 // ReadPump читает сообщения от клиента
 func (c *Client) ReadPump(handler ClientMessageHandler) {
 	defer func() {
-		c.Hub.unregister <- c
+		c.Hub.Unregister(c)
 		c.Conn.Close()
 	}()
 
@@ -64,14 +73,38 @@ func (c *Client) ReadPump(handler ClientMessageHandler) {
 
 		msg.UserID = c.UserID
 
+		if msg.Type != TypePong {
+			c.Hub.touch(c)
+		}
+
 		switch msg.Type {
 		case TypePong:
 			continue
 
+		case TypeUserStatus:
+			var update PresenceUpdate
+			if err := json.Unmarshal(msg.Data, &update); err != nil {
+				c.SendError(ErrInvalidMessage.Error())
+				continue
+			}
+			if _, err := c.Hub.SetPresence(c.UserID, update); err != nil {
+				c.SendError(err.Error())
+			}
+			continue
+
 		case TypeRoomJoin:
 			if msg.RoomID != nil {
-				c.Hub.JoinRoom(c, *msg.RoomID)
+				c.handleRoomJoin(*msg.RoomID)
+			}
+			continue
+
+		case TypeResume:
+			var req ResumeRequest
+			if err := json.Unmarshal(msg.Data, &req); err != nil {
+				c.SendError(ErrInvalidMessage.Error())
+				continue
 			}
+			c.Hub.Resume(c, req)
 			continue
 
 		case TypeRoomLeave:
@@ -79,6 +112,18 @@ func (c *Client) ReadPump(handler ClientMessageHandler) {
 				c.Hub.LeaveRoom(c, *msg.RoomID)
 			}
 			continue
+
+		case TypeTypingStart:
+			if msg.RoomID != nil {
+				c.Hub.StartTyping(c, *msg.RoomID)
+			}
+			continue
+
+		case TypeTypingStop:
+			if msg.RoomID != nil {
+				c.Hub.StopTyping(c, *msg.RoomID)
+			}
+			continue
 		}
 
 		if handler != nil {
@@ -96,6 +141,7 @@ func (c *Client) WritePump() {
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
+		close(c.done)
 	}()
 
 	for {
@@ -103,17 +149,25 @@ func (c *Client) WritePump() {
 		case message, ok := <-c.Send:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				// Hub закрыл канал
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.writeClose()
 				return
 			}
 
-			c.Conn.WriteMessage(websocket.TextMessage, message)
+			if err := c.writeFrame(message); err != nil {
+				return
+			}
 
-			// Отправляем все накопившиеся сообщения
-			n := len(c.Send)
-			for i := 0; i < n; i++ {
-				<-c.Send
+			// Дописываем все, что накопилось, под тем же дедлайном. Каждое событие
+			// остается отдельным кадром: клиенты разбирают по одному JSON на кадр
+			for n := len(c.Send); n > 0; n-- {
+				message, ok := <-c.Send
+				if !ok {
+					c.writeClose()
+					return
+				}
+				if err := c.writeFrame(message); err != nil {
+					return
+				}
 			}
 
 		case <-ticker.C:
@@ -144,16 +198,11 @@ func (c *Client) SendMessage(msgType MessageType, data interface{}) error {
 		return err
 	}
 
-	select {
-	case c.Send <- msgData:
-		return nil
-	default:
-		return ErrClientQueueFull
-	}
+	return c.enqueue(msgData)
 }
 
 func (c *Client) SendError(errorMsg string) {
-	c.SendMessage("error", map[string]string{
+	c.SendMessage(TypeError, map[string]string{
 		"error": errorMsg,
 	})
 }
@@ -0,0 +1,505 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	// Канал Redis, через который инстансы обмениваются событиями хаба
+	clusterChannel = "ws:events"
+
+	// Множество инстансов, на которых у пользователя есть соединения
+	presenceKeyPrefix = "ws:presence:"
+
+	// Hash инстанса userID -> количество соединений
+	nodeUsersKeyPrefix = "ws:node:"
+
+	// Sorted set живых инстансов: nodeID -> время последнего heartbeat (unix ms)
+	nodesKey = "ws:nodes"
+
+	// Как часто инстанс подтверждает, что жив
+	nodeHeartbeatPeriod = 10 * time.Second
+
+	// Инстанс без heartbeat дольше этого считается упавшим, его соединения не учитываются
+	nodeTTL = 30 * time.Second
+
+	// Сколько хранятся счетчики упавшего инстанса, пока их не разберет другой
+	nodeStateTTL = 5 * time.Minute
+
+	// Множество инстансов пользователя живет, пока он подключается хотя бы раз за этот срок
+	presenceSetTTL = 24 * time.Hour
+
+	// Размер очереди исходящих событий кластера
+	clusterQueueSize = 1024
+
+	// Таймаут одной операции с Redis
+	clusterOpTimeout = 5 * time.Second
+)
+
+type envelopeKind string
+
+const (
+	envelopeRoom     envelopeKind = "room"
+	envelopeUser     envelopeKind = "user"
+	envelopePresence envelopeKind = "presence"
+
+	// Принудительная отписка пользователя от комнаты
+	envelopeUnsubscribe envelopeKind = "unsubscribe"
+
+	// Ответ в треде для подписчиков треда
+	envelopeThread envelopeKind = "thread"
+
+	// Изменение блокировки: UserID заблокировал (или разблокировал) SenderID
+	envelopeBlock envelopeKind = "block"
+
+	// Новый статус пользователя UserID, Payload — готовое событие user_status
+	envelopeStatus envelopeKind = "status"
+
+	// Сброс кэша контактов после появления общей комнаты
+	envelopeContacts envelopeKind = "contacts"
+
+	// Пользователь UserID включил или выключил режим invisible
+	envelopeInvisible envelopeKind = "invisible"
+
+	// Отзыв сессии SessionID пользователя UserID (или всех, кроме неё, если Others)
+	envelopeSession envelopeKind = "session"
+)
+
+// clusterEnvelope событие хаба, пересылаемое между инстансами
+type clusterEnvelope struct {
+	NodeID   string          `json:"node_id"`
+	Kind     envelopeKind    `json:"kind"`
+	RoomID   *uuid.UUID      `json:"room_id,omitempty"`
+	ThreadID *uuid.UUID      `json:"thread_id,omitempty"`
+	UserID   *uuid.UUID      `json:"user_id,omitempty"`
+	Exclude  *uuid.UUID      `json:"exclude,omitempty"`
+	SenderID *uuid.UUID      `json:"sender_id,omitempty"`
+	Status   MessageType     `json:"status,omitempty"`
+	Blocked  bool            `json:"blocked,omitempty"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	UserIDs  []uuid.UUID     `json:"user_ids,omitempty"`
+
+	SessionID string `json:"session_id,omitempty"`
+	Others    bool   `json:"others,omitempty"`
+	Invisible bool   `json:"invisible,omitempty"`
+}
+
+// presenceChange изменение числа соединений пользователя на этом инстансе
+type presenceChange struct {
+	userID uuid.UUID
+	delta  int
+}
+
+type clusterTask struct {
+	envelope *clusterEnvelope
+	presence *presenceChange
+}
+
+// Считает соединения пользователя на живых инстансах по множеству его инстансов
+const liveConnectionsLua = `
+local function live_connections(presence_key, user, cutoff)
+	local total = 0
+	for _, node in ipairs(redis.call('SMEMBERS', presence_key)) do
+		local seen = redis.call('ZSCORE', '` + nodesKey + `', node)
+		if seen and tonumber(seen) >= cutoff then
+			total = total + tonumber(redis.call('HGET', '` + nodeUsersKeyPrefix + `' .. node, user) or '0')
+		end
+	end
+	return total
+end
+`
+
+// Атомарно меняет счетчик соединений инстанса и возвращает сумму по живым инстансам.
+// KEYS: множество инстансов пользователя, hash инстанса.
+// ARGV: nodeID, delta, userID, граница живости, TTL множества (ms), TTL hash (ms), now (ms).
+var presenceScript = redis.NewScript(liveConnectionsLua + `
+local n = redis.call('HINCRBY', KEYS[2], ARGV[3], ARGV[2])
+if n <= 0 then
+	redis.call('HDEL', KEYS[2], ARGV[3])
+	redis.call('SREM', KEYS[1], ARGV[1])
+else
+	redis.call('SADD', KEYS[1], ARGV[1])
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+end
+redis.call('PEXPIRE', KEYS[2], ARGV[6])
+redis.call('ZADD', '` + nodesKey + `', ARGV[7], ARGV[1])
+return live_connections(KEYS[1], ARGV[3], tonumber(ARGV[4]))
+`)
+
+// Для каждого пользователя (KEYS — множества инстансов, ARGV — userID) возвращает 1,
+// если у него есть соединения на живых инстансах. Последний ARGV — граница живости.
+var areOnlineScript = redis.NewScript(liveConnectionsLua + `
+local cutoff = tonumber(ARGV[#ARGV])
+local result = {}
+for i, key in ipairs(KEYS) do
+	if live_connections(key, ARGV[i], cutoff) > 0 then
+		result[i] = 1
+	else
+		result[i] = 0
+	end
+end
+return result
+`)
+
+// Cluster связывает хабы нескольких инстансов через Redis pub/sub
+type Cluster struct {
+	rdb    *redis.Client
+	nodeID string
+	queue  chan clusterTask
+
+	// Закрывается, когда run завершился и очередь отправлена
+	done chan struct{}
+}
+
+// NewCluster создает кластерный слой поверх Redis
+func NewCluster(rdb *redis.Client) *Cluster {
+	return &Cluster{
+		rdb:    rdb,
+		nodeID: uuid.New().String(),
+		queue:  make(chan clusterTask, clusterQueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// NodeID возвращает идентификатор текущего инстанса
+func (c *Cluster) NodeID() string {
+	return c.nodeID
+}
+
+// publish ставит событие в очередь на отправку в Redis
+func (c *Cluster) publish(env *clusterEnvelope) {
+	env.NodeID = c.nodeID
+	c.enqueue(clusterTask{envelope: env})
+}
+
+// trackPresence ставит в очередь изменение числа соединений пользователя
+func (c *Cluster) trackPresence(userID uuid.UUID, delta int) {
+	c.enqueue(clusterTask{presence: &presenceChange{userID: userID, delta: delta}})
+}
+
+func (c *Cluster) enqueue(task clusterTask) {
+	select {
+	case c.queue <- task:
+	default:
+		log.Printf("Cluster queue full, event dropped")
+	}
+}
+
+// run обрабатывает очередь и подписку до остановки хаба
+func (c *Cluster) run(ctx context.Context, h *Hub) {
+	defer close(c.done)
+
+	sub := c.rdb.Subscribe(ctx, clusterChannel)
+	defer sub.Close()
+
+	go c.receive(ctx, sub, h)
+
+	heartbeat := time.NewTicker(nodeHeartbeatPeriod)
+	defer heartbeat.Stop()
+	c.heartbeat(ctx, h)
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.flush()
+			return
+
+		case <-heartbeat.C:
+			c.heartbeat(ctx, h)
+
+		case task := <-c.queue:
+			if task.envelope != nil {
+				c.send(ctx, task.envelope)
+			}
+			if task.presence != nil {
+				c.applyPresence(ctx, h, task.presence)
+			}
+		}
+	}
+}
+
+// flush отправляет события, оставшиеся в очереди при остановке. Изменения присутствия
+// пропускаются: release снимет вклад инстанса целиком.
+func (c *Cluster) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case task := <-c.queue:
+			if task.envelope != nil {
+				c.send(ctx, task.envelope)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (c *Cluster) send(ctx context.Context, env *clusterEnvelope) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("Cluster marshal error: %v", err)
+		return
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, clusterOpTimeout)
+	defer cancel()
+
+	if err := c.rdb.Publish(opCtx, clusterChannel, data).Err(); err != nil {
+		log.Printf("Cluster publish error: %v", err)
+	}
+}
+
+// applyPresence обновляет счетчики в Redis и рассылает смену статуса,
+// только когда пользователь впервые появился или полностью пропал из кластера
+func (c *Cluster) applyPresence(ctx context.Context, h *Hub, change *presenceChange) {
+	total, err := c.updatePresence(ctx, change.userID, change.delta)
+	if err != nil {
+		log.Printf("Cluster presence error: %v", err)
+		return
+	}
+
+	var status MessageType
+	switch {
+	case change.delta > 0 && total == int64(change.delta):
+		status = TypeUserOnline
+	case change.delta < 0 && total == 0:
+		status = TypeUserOffline
+	default:
+		return
+	}
+
+	// Invisible пользователь для остальных не подключается и не отключается
+	if h.isInvisible(change.userID) {
+		return
+	}
+
+	h.notifyUserStatus(change.userID, status)
+
+	userID := change.userID
+	c.send(ctx, &clusterEnvelope{
+		NodeID: c.nodeID,
+		Kind:   envelopePresence,
+		UserID: &userID,
+		Status: status,
+	})
+}
+
+func (c *Cluster) updatePresence(ctx context.Context, userID uuid.UUID, delta int) (int64, error) {
+	opCtx, cancel := context.WithTimeout(ctx, clusterOpTimeout)
+	defer cancel()
+
+	now := time.Now()
+	keys := []string{presenceKeyPrefix + userID.String(), nodeUsersKeyPrefix + c.nodeID}
+	return presenceScript.Run(opCtx, c.rdb, keys,
+		c.nodeID, delta, userID.String(), liveCutoff(now),
+		presenceSetTTL.Milliseconds(), nodeStateTTL.Milliseconds(), now.UnixMilli(),
+	).Int64()
+}
+
+// liveCutoff граница живости: инстансы с heartbeat раньше нее считаются упавшими
+func liveCutoff(now time.Time) int64 {
+	return now.Add(-nodeTTL).UnixMilli()
+}
+
+// heartbeat продлевает жизнь инстанса и разбирает упавшие
+func (c *Cluster) heartbeat(ctx context.Context, h *Hub) {
+	opCtx, cancel := context.WithTimeout(ctx, clusterOpTimeout)
+	defer cancel()
+
+	_, err := c.rdb.TxPipelined(opCtx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(opCtx, nodesKey, &redis.Z{Score: float64(time.Now().UnixMilli()), Member: c.nodeID})
+		pipe.PExpire(opCtx, nodeUsersKeyPrefix+c.nodeID, nodeStateTTL)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Cluster heartbeat error: %v", err)
+		return
+	}
+
+	c.reapDeadNodes(opCtx, h)
+}
+
+// reapDeadNodes снимает соединения инстансов, переставших присылать heartbeat, и рассылает
+// user_offline тем их пользователям, кто больше нигде не подключен. Каждый упавший
+// инстанс разбирает тот, кто первым удалил его из nodesKey.
+func (c *Cluster) reapDeadNodes(ctx context.Context, h *Hub) {
+	dead, err := c.rdb.ZRangeByScore(ctx, nodesKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(liveCutoff(time.Now()), 10),
+	}).Result()
+	if err != nil {
+		log.Printf("Cluster reap error: %v", err)
+		return
+	}
+
+	for _, nodeID := range dead {
+		removed, err := c.rdb.ZRem(ctx, nodesKey, nodeID).Result()
+		if err != nil || removed == 0 {
+			continue
+		}
+
+		nodeKey := nodeUsersKeyPrefix + nodeID
+		members, err := c.rdb.HKeys(ctx, nodeKey).Result()
+		if err != nil {
+			log.Printf("Cluster reap error: %v", err)
+			continue
+		}
+
+		userIDs := make([]uuid.UUID, 0, len(members))
+		for _, m := range members {
+			if id, err := uuid.Parse(m); err == nil {
+				userIDs = append(userIDs, id)
+				c.rdb.SRem(ctx, presenceKeyPrefix+m, nodeID)
+			}
+		}
+		c.rdb.Del(ctx, nodeKey)
+
+		if len(userIDs) == 0 {
+			continue
+		}
+
+		online, err := c.areOnline(userIDs)
+		if err != nil {
+			log.Printf("Cluster reap error: %v", err)
+			continue
+		}
+
+		for i, userID := range userIDs {
+			if online[i] || h.isInvisible(userID) {
+				continue
+			}
+
+			h.notifyUserStatus(userID, TypeUserOffline)
+
+			id := userID
+			c.send(ctx, &clusterEnvelope{
+				NodeID: c.nodeID,
+				Kind:   envelopePresence,
+				UserID: &id,
+				Status: TypeUserOffline,
+			})
+		}
+	}
+}
+
+// receive доставляет локальным клиентам события других инстансов
+func (c *Cluster) receive(ctx context.Context, sub *redis.PubSub, h *Hub) {
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var env clusterEnvelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				log.Printf("Cluster invalid event: %v", err)
+				continue
+			}
+
+			// Свои события уже доставлены локально
+			if env.NodeID == c.nodeID {
+				continue
+			}
+
+			h.deliverEnvelope(&env)
+		}
+	}
+}
+
+// onlineUsers возвращает пользователей, подключенных к любому живому инстансу
+func (c *Cluster) onlineUsers() ([]uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	nodes, err := c.rdb.ZRangeByScore(ctx, nodesKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(liveCutoff(time.Now()), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	users := make([]uuid.UUID, 0)
+	for _, nodeID := range nodes {
+		members, err := c.rdb.HKeys(ctx, nodeUsersKeyPrefix+nodeID).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range members {
+			if id, err := uuid.Parse(m); err == nil && !seen[id] {
+				seen[id] = true
+				users = append(users, id)
+			}
+		}
+	}
+	return users, nil
+}
+
+// areOnline проверяет, кто из userIDs подключен хотя бы к одному живому инстансу
+func (c *Cluster) areOnline(userIDs []uuid.UUID) ([]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	keys := make([]string, len(userIDs))
+	args := make([]interface{}, len(userIDs)+1)
+	for i, userID := range userIDs {
+		keys[i] = presenceKeyPrefix + userID.String()
+		args[i] = userID.String()
+	}
+	args[len(userIDs)] = liveCutoff(time.Now())
+
+	values, err := areOnlineScript.Run(ctx, c.rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	online := make([]bool, len(values))
+	for i, v := range values {
+		online[i] = v == 1
+	}
+	return online, nil
+}
+
+// release снимает вклад инстанса в присутствие пользователей при остановке
+func (c *Cluster) release(counts map[uuid.UUID]int) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	for userID, n := range counts {
+		total, err := c.updatePresence(ctx, userID, -n)
+		if err != nil {
+			log.Printf("Cluster release error: %v", err)
+			continue
+		}
+
+		// Invisible пользователь для остальных уже отключен
+		if total == 0 && !isInvisibleIn(c, userID) {
+			id := userID
+			c.send(ctx, &clusterEnvelope{
+				NodeID: c.nodeID,
+				Kind:   envelopePresence,
+				UserID: &id,
+				Status: TypeUserOffline,
+			})
+		}
+	}
+
+	c.rdb.ZRem(ctx, nodesKey, c.nodeID)
+	c.rdb.Del(ctx, nodeUsersKeyPrefix+c.nodeID)
+}
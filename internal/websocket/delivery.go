@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"expvar"
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Размер очереди исходящих кадров клиента
+	sendQueueSize = 256
+
+	// Сколько кадров подряд может не поместиться в очередь, прежде чем клиент
+	// будет признан медленным и отключен
+	slowConsumerLimit = 32
+
+	// Код закрытия для медленного клиента (диапазон приложения 4000-4999).
+	// Клиенту стоит переподключиться и догрузить пропущенное через REST.
+	CloseSlowConsumer = 4008
+)
+
+// Счетчики доставки, доступны через expvar в /debug/vars на внутреннем DEBUG_ADDR
+var (
+	framesSent              = expvar.NewInt("ws_frames_sent")
+	framesDropped           = expvar.NewInt("ws_frames_dropped")
+	slowConsumerDisconnects = expvar.NewInt("ws_slow_consumer_disconnects")
+)
+
+// enqueue ставит кадр в очередь отправки. После закрытия клиента кадры отбрасываются,
+// поэтому отправка безопасна в любой момент, в том числе во время Shutdown.
+// Клиент, очередь которого остается полной slowConsumerLimit кадров подряд, отключается:
+// лучше явно разорвать соединение, чем молча терять события.
+func (c *Client) enqueue(data []byte) error {
+	err := c.tryEnqueue(data)
+	if err != ErrClientQueueFull {
+		return err
+	}
+
+	framesDropped.Add(1)
+	if c.drops.Add(1) >= slowConsumerLimit {
+		c.disconnectSlow()
+	}
+	return err
+}
+
+func (c *Client) tryEnqueue(data []byte) error {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+
+	if c.closed {
+		return ErrClientClosed
+	}
+
+	select {
+	case c.Send <- data:
+		c.drops.Store(0)
+		return nil
+	default:
+		return ErrClientQueueFull
+	}
+}
+
+// close закрывает очередь отправки ровно один раз. WritePump допишет очередь
+// и попрощается close frame с переданным кодом.
+func (c *Client) close(code int, reason string) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.closeLocked(code, reason)
+}
+
+// disconnectSlow отключает клиента, который не успевает читать. Устаревшие кадры
+// из очереди отбрасываются, чтобы close frame дошел сразу. Из хаба клиента убирает
+// ReadPump, когда соединение закроется.
+func (c *Client) disconnectSlow() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	discarded := 0
+	for len(c.Send) > 0 {
+		select {
+		case <-c.Send:
+			discarded++
+		default:
+		}
+	}
+	framesDropped.Add(int64(discarded))
+	slowConsumerDisconnects.Add(1)
+
+	log.Printf("Client %s (User: %s) is too slow, disconnecting", c.ID, c.UserID)
+	c.closeLocked(CloseSlowConsumer, "slow consumer, reconnect")
+}
+
+func (c *Client) closeLocked(code int, reason string) {
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	c.closeCode = code
+	c.closeReason = reason
+	close(c.Send)
+}
+
+// writeFrame пишет одно событие отдельным текстовым кадром
+func (c *Client) writeFrame(message []byte) error {
+	if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		return err
+	}
+	framesSent.Add(1)
+	return nil
+}
+
+// writeClose прощается с клиентом после того, как очередь закрыта и дописана
+func (c *Client) writeClose() {
+	c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason))
+}
@@ -4,8 +4,10 @@ import "errors"
 
 var (
 	ErrClientQueueFull = errors.New("client message queue is full")
+	ErrClientClosed    = errors.New("client connection is closed")
 	ErrInvalidMessage  = errors.New("invalid message format")
 	ErrUnauthorized    = errors.New("unauthorized")
 	ErrRoomNotFound    = errors.New("room not found")
 	ErrUserNotInRoom   = errors.New("user not in room")
+	ErrMembershipCheck = errors.New("failed to check room membership")
 )
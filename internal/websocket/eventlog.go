@@ -0,0 +1,219 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	// Сколько последних событий комнаты хранится для досылки
+	replayLimit = 500
+
+	// Счетчик последовательности комнаты. Не истекает: сброс номера сломал бы resume
+	roomSeqKeyPrefix = "ws:seq:"
+
+	// Stream последних событий комнаты, ID записи "<seq>-1"
+	roomEventsKeyPrefix = "ws:room-events:"
+
+	// Сколько живет stream неактивной комнаты
+	roomEventsTTL = 24 * time.Hour
+
+	// Как часто журнал в памяти удаляет неактивные комнаты
+	eventLogSweepInterval = time.Hour
+)
+
+// replayEvent событие комнаты с его номером; payload хранится без seq.
+// senderID нужен для фильтрации блокировок, threadID — чтобы ответы треда
+// досылались только подписчикам треда. Оба uuid.Nil, если не заданы.
+type replayEvent struct {
+	seq      uint64
+	senderID uuid.UUID
+	threadID uuid.UUID
+	payload  []byte
+}
+
+// eventLog нумерует события комнат и хранит ограниченный хвост для досылки
+type eventLog interface {
+	// append присваивает событию следующий номер комнаты и сохраняет его
+	append(roomID, senderID, threadID uuid.UUID, payload []byte) (uint64, error)
+
+	// since возвращает события с номером больше after. complete равен false, если
+	// часть пропущенных событий уже вытеснена и клиенту нужно догрузить историю через REST.
+	since(roomID uuid.UUID, after uint64) (events []replayEvent, complete bool, err error)
+}
+
+// memoryEventLog журнал событий для работы в одном инстансе. Комнаты без событий
+// дольше roomEventsTTL удаляются, как и stream в Redis.
+type memoryEventLog struct {
+	mu    sync.Mutex
+	rooms map[uuid.UUID]*roomEventBuffer
+	swept time.Time
+}
+
+// roomEventBuffer хвост событий комнаты. Нумерация начинается с base — времени
+// создания буфера в микросекундах, поэтому после удаления неактивной комнаты или
+// рестарта номера не повторяются и старый номер клиента не скрывает новые события.
+type roomEventBuffer struct {
+	base    uint64
+	seq     uint64
+	events  []replayEvent
+	updated time.Time
+}
+
+func newMemoryEventLog() *memoryEventLog {
+	return &memoryEventLog{rooms: make(map[uuid.UUID]*roomEventBuffer), swept: time.Now()}
+}
+
+func (l *memoryEventLog) append(roomID, senderID, threadID uuid.UUID, payload []byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) >= eventLogSweepInterval {
+		l.sweep(now)
+	}
+
+	buf, ok := l.rooms[roomID]
+	if !ok {
+		base := uint64(now.UnixMicro())
+		buf = &roomEventBuffer{base: base, seq: base}
+		l.rooms[roomID] = buf
+	}
+
+	buf.seq++
+	buf.updated = now
+	if len(buf.events) >= replayLimit {
+		copy(buf.events, buf.events[1:])
+		buf.events = buf.events[:len(buf.events)-1]
+	}
+	buf.events = append(buf.events, replayEvent{seq: buf.seq, senderID: senderID, threadID: threadID, payload: payload})
+	return buf.seq, nil
+}
+
+// sweep удаляет комнаты без событий дольше roomEventsTTL. Вызывающий держит l.mu.
+func (l *memoryEventLog) sweep(now time.Time) {
+	for roomID, buf := range l.rooms {
+		if now.Sub(buf.updated) > roomEventsTTL {
+			delete(l.rooms, roomID)
+		}
+	}
+	l.swept = now
+}
+
+func (l *memoryEventLog) since(roomID uuid.UUID, after uint64) ([]replayEvent, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buf, ok := l.rooms[roomID]
+	if !ok {
+		return nil, after == 0, nil
+	}
+
+	// Клиент без номера не видел ни одного события комнаты
+	if after == 0 {
+		after = buf.base
+	}
+
+	events := make([]replayEvent, 0)
+	for _, ev := range buf.events {
+		if ev.seq > after {
+			events = append(events, ev)
+		}
+	}
+	return events, isComplete(events, after, buf.seq), nil
+}
+
+// isComplete проверяет, что events покрывают все номера после after вплоть до current
+func isComplete(events []replayEvent, after, current uint64) bool {
+	if after > current {
+		return false
+	}
+	if after == current {
+		return true
+	}
+	return len(events) > 0 && events[0].seq == after+1
+}
+
+// Атомарно выдает номер события и дописывает его в ограниченный stream комнаты
+var appendEventScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[2], seq .. '-1', 'p', ARGV[1], 's', ARGV[4], 't', ARGV[5])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+return seq
+`)
+
+// append нумерует событие комнаты, общий счетчик в Redis дает один порядок на всех инстансах
+func (c *Cluster) append(roomID, senderID, threadID uuid.UUID, payload []byte) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	keys := []string{roomSeqKeyPrefix + roomID.String(), roomEventsKeyPrefix + roomID.String()}
+	seq, err := appendEventScript.Run(ctx, c.rdb, keys, payload, replayLimit, int(roomEventsTTL.Seconds()), senderID.String(), threadID.String()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(seq), nil
+}
+
+func (c *Cluster) since(roomID uuid.UUID, after uint64) ([]replayEvent, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	var seqCmd *redis.StringCmd
+	var rangeCmd *redis.XMessageSliceCmd
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		seqCmd = pipe.Get(ctx, roomSeqKeyPrefix+roomID.String())
+		rangeCmd = pipe.XRangeN(ctx, roomEventsKeyPrefix+roomID.String(), fmt.Sprintf("%d-0", after+1), "+", replayLimit)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, false, err
+	}
+
+	var current uint64
+	if raw, err := seqCmd.Result(); err == nil {
+		if current, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			return nil, false, err
+		}
+	} else if err != redis.Nil {
+		return nil, false, err
+	}
+
+	entries, err := rangeCmd.Result()
+	if err != nil && err != redis.Nil {
+		return nil, false, err
+	}
+
+	events := make([]replayEvent, 0, len(entries))
+	for _, entry := range entries {
+		seq, err := strconv.ParseUint(strings.SplitN(entry.ID, "-", 2)[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		payload, _ := entry.Values["p"].(string)
+		events = append(events, replayEvent{
+			seq:      seq,
+			senderID: parseEventID(entry.Values["s"]),
+			threadID: parseEventID(entry.Values["t"]),
+			payload:  []byte(payload),
+		})
+	}
+	return events, isComplete(events, after, current), nil
+}
+
+// parseEventID разбирает UUID из поля записи stream, uuid.Nil если поля нет
+func parseEventID(value interface{}) uuid.UUID {
+	raw, _ := value.(string)
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,17 +21,57 @@ const (
 	TypeDisconnect MessageType = "disconnect"
 	TypePing       MessageType = "ping"
 	TypePong       MessageType = "pong"
+	TypeError      MessageType = "error"
 
 	// Типы сообщений
 	TypeMessage       MessageType = "message"
 	TypeMessageEdit   MessageType = "message_edit"
 	TypeMessageDelete MessageType = "message_delete"
 
+	// Восстановление удаленного сообщения администратором
+	TypeMessageRestored MessageType = "message_restored"
+
+	// Индикаторы набора текста, не сохраняются в БД
+	TypeTypingStart MessageType = "typing_start"
+	TypeTypingStop  MessageType = "typing_stop"
+
+	// Типы реакций
+	TypeReactionAdded   MessageType = "reaction_added"
+	TypeReactionRemoved MessageType = "reaction_removed"
+
+	// Типы прочтения
+	TypeMessageRead MessageType = "message_read"
+	TypeReadReceipt MessageType = "read_receipt"
+	TypeUnreadCount MessageType = "unread_count"
+
 	// Типы комнат
 	TypeRoomJoin  MessageType = "room_join"
 	TypeRoomLeave MessageType = "room_leave"
 	TypeRoomUsers MessageType = "room_users"
 
+	// Подтверждение подписки на комнату после проверки членства
+	TypeRoomJoined MessageType = "room_joined"
+
+	// Возобновление сессии после переподключения с досылкой пропущенных событий
+	TypeResume  MessageType = "resume"
+	TypeResumed MessageType = "resumed"
+
+	// Типы тредов
+	TypeThreadSubscribe   MessageType = "thread_subscribe"
+	TypeThreadUnsubscribe MessageType = "thread_unsubscribe"
+	TypeThreadUpdated     MessageType = "thread_updated"
+
+	// Типы управления участниками
+	TypeMemberRoleChanged MessageType = "member_role_changed"
+	TypeMemberKicked      MessageType = "member_kicked"
+	TypeMemberBanned      MessageType = "member_banned"
+
+	// Друзья: входящая заявка, отмена заявки, принятие и удаление из друзей
+	TypeFriendRequest         MessageType = "friend_request"
+	TypeFriendRequestCanceled MessageType = "friend_request_canceled"
+	TypeFriendAdded           MessageType = "friend_added"
+	TypeFriendRemoved         MessageType = "friend_removed"
+
 	// Типы статусов
 	TypeUserStatus  MessageType = "user_status"
 	TypeUserOnline  MessageType = "user_online"
@@ -40,9 +81,13 @@ const (
 type Message struct {
 	Type      MessageType     `json:"type"`
 	RoomID    *uuid.UUID      `json:"room_id,omitempty"`
+	ThreadID  *uuid.UUID      `json:"thread_id,omitempty"`
 	UserID    uuid.UUID       `json:"user_id"`
 	Data      json.RawMessage `json:"data"`
 	Timestamp time.Time       `json:"timestamp"`
+
+	// Номер события в комнате, растет монотонно; 0 у событий, которые не досылаются
+	Seq uint64 `json:"seq,omitempty"`
 }
 
 type Client struct {
@@ -53,6 +98,37 @@ type Client struct {
 	Rooms  map[uuid.UUID]bool
 	Hub    *Hub
 	mu     sync.RWMutex
+
+	// Открытые треды: threadID -> roomID
+	Threads map[uuid.UUID]uuid.UUID
+
+	// Сессия, токеном которой открыто соединение; при её отзыве соединение закрывается
+	SessionID string
+
+	// Активные индикаторы набора текста по комнатам
+	typing map[uuid.UUID]*typingState
+
+	// Закрытие очереди отправки; код и причина уходят клиенту в close frame
+	sendMu      sync.RWMutex
+	closed      bool
+	closeCode   int
+	closeReason string
+
+	// Кадры подряд, не поместившиеся в очередь
+	drops atomic.Int32
+
+	// Когда активность соединения последний раз записана в хранилище статусов (unix ms)
+	lastTouch atomic.Int64
+
+	// Режим invisible, прочитанный из хранилища статусов до регистрации
+	invisible bool
+
+	// Закрывается, когда WritePump дописал очередь и завершился
+	done chan struct{}
+
+	// Комнаты, по которым идет досылка: придержанные живые события
+	resumeMu sync.Mutex
+	resuming map[uuid.UUID][][]byte
 }
 
 type Hub struct {
@@ -64,6 +140,9 @@ type Hub struct {
 	// Клиенты в комнатах
 	rooms map[uuid.UUID]map[uuid.UUID]*Client
 
+	// Подписчики тредов
+	threads map[uuid.UUID]map[uuid.UUID]*Client
+
 	// Каналы для регистрации/отмены регистрации
 	register   chan *Client
 	unregister chan *Client
@@ -72,9 +151,46 @@ type Hub struct {
 
 	mu sync.RWMutex
 
+	// Кластерный слой, nil при работе в одном инстансе
+	cluster *Cluster
+
+	// Нумерация и хвост событий комнат для досылки при переподключении
+	events eventLog
+
+	// Номер события выдается и событие ставится в очереди под одной блокировкой
+	// комнаты, чтобы клиенты получали события в порядке seq. Берется до h.mu.
+	roomSeqMu [roomSeqStripes]sync.Mutex
+
+	// Выбранные пользователями статусы и автоматический idle
+	presence presenceStore
+
+	// Проверка членства для подписки на комнаты и ее кэш
+	membership MembershipChecker
+	members    *membershipCache
+
+	// Кому виден статус пользователя и кэш контактов
+	contactSource ContactSource
+	contacts      *contactsCache
+
+	// События присутствия, ожидающие рассылки
+	pendingMu sync.Mutex
+	pending   map[uuid.UUID]*pendingPresence
+
+	// Блокировки подключенных пользователей: кого заблокировал и кем заблокирован
+	blocked   map[uuid.UUID]map[uuid.UUID]bool
+	blockedBy map[uuid.UUID]map[uuid.UUID]bool
+
+	// Блокировки остальных пользователей для REST запросов
+	blockSource BlockSource
+
+	// Режим invisible подключенных пользователей. Под h.mu статус читается отсюда,
+	// а не из хранилища: в кластере это был бы запрос к Redis под блокировкой.
+	invisible map[uuid.UUID]bool
+
 	// Контекст для graceful shutdown
-	ctx    context.Context
-	cancel context.CancelFunc
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped bool
 }
 
 type BroadcastMessage struct {
@@ -91,19 +207,42 @@ func NewHub() *Hub {
 		clients:     make(map[uuid.UUID]*Client),
 		userClients: make(map[uuid.UUID]map[uuid.UUID]*Client),
 		rooms:       make(map[uuid.UUID]map[uuid.UUID]*Client),
+		threads:     make(map[uuid.UUID]map[uuid.UUID]*Client),
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
 		broadcast:   make(chan *BroadcastMessage),
+		blocked:     make(map[uuid.UUID]map[uuid.UUID]bool),
+		blockedBy:   make(map[uuid.UUID]map[uuid.UUID]bool),
+		invisible:   make(map[uuid.UUID]bool),
+		events:      newMemoryEventLog(),
+		members:     newMembershipCache(),
+		presence:    newMemoryPresenceStore(),
+		contacts:    newContactsCache(),
+		pending:     make(map[uuid.UUID]*pendingPresence),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
 }
 
+// SetCluster включает рассылку событий между инстансами. Вызывается до Run.
+// Номера событий комнат, журнал для досылки и статусы пользователей при этом переезжают в Redis.
+func (h *Hub) SetCluster(cluster *Cluster) {
+	h.cluster = cluster
+	h.events = cluster
+	h.presence = cluster
+}
+
 // Run запускает hub
 func (h *Hub) Run() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	if h.cluster != nil {
+		go h.cluster.run(h.ctx, h)
+	}
+	go h.runIdleSweep()
+	go h.runPresenceFlush()
+
 	for {
 		select {
 		case <-h.ctx.Done():
@@ -124,33 +263,94 @@ func (h *Hub) Run() {
 	}
 }
 
-// Stop останавливает hub
-func (h *Hub) Stop() {
-	h.cancel()
-
+// Shutdown останавливает hub: закрывает всех клиентов кодом 1012 (service restart) с подсказкой
+// переподключиться, ждет, пока их очереди будут дописаны, и снимает присутствие инстанса в кластере.
+// Соединения, не закрывшиеся до отмены ctx, обрываются.
+func (h *Hub) Shutdown(ctx context.Context) error {
 	h.mu.Lock()
-	defer h.mu.Unlock()
+	if h.stopped {
+		h.mu.Unlock()
+		return nil
+	}
+	h.stopped = true
 
+	counts := make(map[uuid.UUID]int, len(h.userClients))
+	for userID, clients := range h.userClients {
+		counts[userID] = len(clients)
+	}
+
+	clients := make([]*Client, 0, len(h.clients))
 	for _, client := range h.clients {
-		close(client.Send)
-		client.Conn.Close()
+		for _, roomID := range client.GetRooms() {
+			client.clearTyping(roomID)
+		}
+		client.close(websocket.CloseServiceRestart, shutdownCloseReason)
+		clients = append(clients, client)
+	}
+
+	// Без клиентов в индексах поздние рассылки просто никого не находят
+	h.clients = make(map[uuid.UUID]*Client)
+	h.userClients = make(map[uuid.UUID]map[uuid.UUID]*Client)
+	h.rooms = make(map[uuid.UUID]map[uuid.UUID]*Client)
+	h.threads = make(map[uuid.UUID]map[uuid.UUID]*Client)
+	h.invisible = make(map[uuid.UUID]bool)
+	h.mu.Unlock()
+
+	h.cancel()
+
+	var err error
+	for _, client := range clients {
+		select {
+		case <-client.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+			client.Conn.Close()
+		}
 	}
+
+	if h.cluster != nil {
+		select {
+		case <-h.cluster.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		h.cluster.release(counts)
+	}
+
+	return err
 }
 
-// Register регистрирует нового клиента
+// Register регистрирует нового клиента. После остановки hub клиент сразу закрывается.
+// Новое соединение считается активностью пользователя.
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	h.touch(client)
+	client.invisible = h.isInvisible(client.UserID)
+
+	select {
+	case h.register <- client:
+	case <-h.ctx.Done():
+		client.close(websocket.CloseServiceRestart, shutdownCloseReason)
+	}
 }
 
-// Unregister отменяет регистрацию клиента
+// Unregister отменяет регистрацию клиента. После остановки hub клиенты уже закрыты.
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.ctx.Done():
+	}
 }
 
 func (h *Hub) registerClient(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	// Регистрация могла прийти одновременно с Shutdown
+	if h.stopped {
+		client.close(websocket.CloseServiceRestart, shutdownCloseReason)
+		return
+	}
+
 	h.clients[client.ID] = client
 
 	if _, ok := h.userClients[client.UserID]; !ok {
@@ -158,10 +358,23 @@ func (h *Hub) registerClient(client *Client) {
 	}
 	h.userClients[client.UserID][client.ID] = client
 
+	// У уже подключенного пользователя кэш актуальнее прочитанного при подключении
+	if _, ok := h.invisible[client.UserID]; !ok {
+		h.invisible[client.UserID] = client.invisible
+	}
+
 	log.Printf("Client registered: %s (User: %s)", client.ID, client.UserID)
 
-	// Отправляем уведомление о подключении пользователя
-	h.notifyUserStatus(client.UserID, TypeUserOnline)
+	// В кластере статус определяется по соединениям на всех инстансах
+	if h.cluster != nil {
+		h.cluster.trackPresence(client.UserID, 1)
+		return
+	}
+
+	// Отправляем уведомление о подключении пользователя, если это его первое соединение
+	if len(h.userClients[client.UserID]) == 1 && !h.invisible[client.UserID] {
+		h.notifyUserStatus(client.UserID, TypeUserOnline)
+	}
 }
 
 func (h *Hub) unregisterClient(client *Client) {
@@ -179,23 +392,37 @@ func (h *Hub) unregisterClient(client *Client) {
 			delete(userClients, client.ID)
 			if len(userClients) == 0 {
 				delete(h.userClients, client.UserID)
+				delete(h.blocked, client.UserID)
+				delete(h.blockedBy, client.UserID)
 				// Отправляем уведомление об отключении пользователя
-				h.notifyUserStatus(client.UserID, TypeUserOffline)
+				if h.cluster == nil && !h.invisible[client.UserID] {
+					h.notifyUserStatus(client.UserID, TypeUserOffline)
+				}
+				delete(h.invisible, client.UserID)
 			}
 		}
 
+		if h.cluster != nil {
+			h.cluster.trackPresence(client.UserID, -1)
+		}
+
 		delete(h.clients, client.ID)
-		close(client.Send)
+		client.close(websocket.CloseNormalClosure, "")
 
 		log.Printf("Client unregistered: %s (User: %s)", client.ID, client.UserID)
 	}
 }
 
-// JoinRoom добавляет клиента в комнату
+// JoinRoom добавляет клиента в комнату без проверки членства.
+// Запросы клиентов идут через JoinRoomAuthorized.
 func (h *Hub) JoinRoom(client *Client, roomID uuid.UUID) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if h.stopped {
+		return
+	}
+
 	if _, ok := h.rooms[roomID]; !ok {
 		h.rooms[roomID] = make(map[uuid.UUID]*Client)
 	}
@@ -205,16 +432,18 @@ func (h *Hub) JoinRoom(client *Client, roomID uuid.UUID) {
 	client.Rooms[roomID] = true
 	client.mu.Unlock()
 
-	// Уведомляем других участников о присоединении
-	joinMsg := Message{
-		Type:      TypeRoomJoin,
-		RoomID:    &roomID,
-		UserID:    client.UserID,
-		Timestamp: time.Now(),
-	}
+	// Уведомляем других участников о присоединении; invisible пользователь входит незаметно
+	if !h.isInvisibleLocal(client) {
+		joinMsg := Message{
+			Type:      TypeRoomJoin,
+			RoomID:    &roomID,
+			UserID:    client.UserID,
+			Timestamp: time.Now(),
+		}
 
-	if data, err := json.Marshal(joinMsg); err == nil {
-		h.broadcastToRoomExcept(roomID, data, client.ID)
+		if data, err := json.Marshal(joinMsg); err == nil {
+			h.broadcastToRoomExcept(roomID, data, client.ID)
+		}
 	}
 
 	// Отправляем список участников новому клиенту
@@ -232,6 +461,9 @@ func (h *Hub) LeaveRoom(client *Client, roomID uuid.UUID) {
 func (h *Hub) removeFromRoomUnsafe(client *Client, roomID uuid.UUID) {
 	if room, ok := h.rooms[roomID]; ok {
 		if _, ok := room[client.ID]; ok {
+			wasTyping := client.clearTyping(roomID)
+			h.unsubscribeRoomThreadsUnsafe(client, roomID)
+
 			delete(room, client.ID)
 			client.mu.Lock()
 			delete(client.Rooms, roomID)
@@ -240,46 +472,130 @@ func (h *Hub) removeFromRoomUnsafe(client *Client, roomID uuid.UUID) {
 			if len(room) == 0 {
 				delete(h.rooms, roomID)
 			} else {
-				// Уведомляем других участников
-				leaveMsg := Message{
-					Type:      TypeRoomLeave,
-					RoomID:    &roomID,
-					UserID:    client.UserID,
-					Timestamp: time.Now(),
+				if wasTyping {
+					typingMsg := Message{
+						Type:      TypeTypingStop,
+						RoomID:    &roomID,
+						UserID:    client.UserID,
+						Timestamp: time.Now(),
+					}
+
+					if data, err := json.Marshal(typingMsg); err == nil {
+						h.broadcastToRoomExcept(roomID, data, client.ID)
+					}
 				}
 
-				if data, err := json.Marshal(leaveMsg); err == nil {
-					h.broadcastToRoomExcept(roomID, data, client.ID)
+				// Уведомляем других участников, если пользователь не invisible
+				if !h.isInvisibleLocal(client) {
+					leaveMsg := Message{
+						Type:      TypeRoomLeave,
+						RoomID:    &roomID,
+						UserID:    client.UserID,
+						Timestamp: time.Now(),
+					}
+
+					if data, err := json.Marshal(leaveMsg); err == nil {
+						h.broadcastToRoomExcept(roomID, data, client.ID)
+					}
 				}
 			}
 		}
 	}
 }
 
-// SendToUser отправляет сообщение пользователю
+// RemoveUserFromRoom отписывает все соединения пользователя от комнаты на всех инстансах
+// и сбрасывает кэш его членства. Вызывается, когда пользователь покидает комнату или исключен.
+func (h *Hub) RemoveUserFromRoom(userID, roomID uuid.UUID) {
+	// Общей комнаты больше нет: контакты пересчитываются на всех инстансах
+	h.InvalidateContacts(userID)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeUserFromRoomLocal(userID, roomID)
+
+	if h.cluster != nil {
+		h.cluster.publish(&clusterEnvelope{
+			Kind:   envelopeUnsubscribe,
+			RoomID: &roomID,
+			UserID: &userID,
+		})
+	}
+}
+
+func (h *Hub) removeUserFromRoomLocal(userID, roomID uuid.UUID) {
+	h.members.invalidate(userID, roomID)
+
+	for _, client := range h.userClients[userID] {
+		h.removeFromRoomUnsafe(client, roomID)
+	}
+}
+
+// SendToUser отправляет сообщение пользователю на всех инстансах
 func (h *Hub) SendToUser(userID uuid.UUID, message []byte) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	h.sendToUserLocal(userID, message)
+
+	if h.cluster != nil {
+		h.cluster.publish(&clusterEnvelope{
+			Kind:    envelopeUser,
+			UserID:  &userID,
+			Payload: message,
+		})
+	}
+}
+
+func (h *Hub) sendToUserLocal(userID uuid.UUID, message []byte) {
 	if clients, ok := h.userClients[userID]; ok {
 		for _, client := range clients {
-			select {
-			case client.Send <- message:
-			default:
+			if err := client.enqueue(message); err == ErrClientQueueFull {
 				log.Printf("Client %s send channel full", client.ID)
 			}
 		}
 	}
 }
 
-// SendToRoom отправляет сообщение в комнату
+// SendToRoom отправляет сообщение в комнату. Событие получает номер и попадает
+// в журнал для досылки.
 func (h *Hub) SendToRoom(roomID uuid.UUID, message []byte) {
+	lock := h.roomSeqLock(roomID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	message = h.sequence(roomID, uuid.Nil, uuid.Nil, message)
+
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	h.broadcastToRoomExcept(roomID, message, uuid.Nil)
 }
 
+// SendToRoomFrom отправляет в комнату событие, созданное senderID.
+// Участники, заблокировавшие отправителя, его не получают.
+func (h *Hub) SendToRoomFrom(roomID, senderID uuid.UUID, message []byte) {
+	lock := h.roomSeqLock(roomID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	message = h.sequence(roomID, senderID, uuid.Nil, message)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	h.broadcastToRoomLocal(roomID, message, uuid.Nil, senderID)
+
+	if h.cluster != nil {
+		h.cluster.publish(&clusterEnvelope{
+			Kind:     envelopeRoom,
+			RoomID:   &roomID,
+			SenderID: &senderID,
+			Payload:  message,
+		})
+	}
+}
+
 func (h *Hub) broadcastMessage(bm *BroadcastMessage) {
 	if bm.RoomID != nil {
 		h.SendToRoom(*bm.RoomID, bm.Message)
@@ -288,13 +604,34 @@ func (h *Hub) broadcastMessage(bm *BroadcastMessage) {
 	}
 }
 
+// broadcastToRoomExcept рассылает сообщение в комнату на всех инстансах.
+// Вызывающий должен держать h.mu.
 func (h *Hub) broadcastToRoomExcept(roomID uuid.UUID, message []byte, excludeID uuid.UUID) {
+	h.broadcastToRoomLocal(roomID, message, excludeID, uuid.Nil)
+
+	if h.cluster != nil {
+		env := &clusterEnvelope{
+			Kind:    envelopeRoom,
+			RoomID:  &roomID,
+			Payload: message,
+		}
+		if excludeID != uuid.Nil {
+			env.Exclude = &excludeID
+		}
+		h.cluster.publish(env)
+	}
+}
+
+// broadcastToRoomLocal доставляет сообщение локальным клиентам комнаты, кроме excludeID.
+// Если senderID задан, пропускает пользователей, заблокировавших отправителя.
+func (h *Hub) broadcastToRoomLocal(roomID uuid.UUID, message []byte, excludeID, senderID uuid.UUID) {
 	if room, ok := h.rooms[roomID]; ok {
 		for _, client := range room {
+			if senderID != uuid.Nil && h.blocked[client.UserID][senderID] {
+				continue
+			}
 			if client.ID != excludeID {
-				select {
-				case client.Send <- message:
-				default:
+				if err := client.enqueueRoom(roomID, message); err == ErrClientQueueFull {
 					log.Printf("Client %s send channel full", client.ID)
 				}
 			}
@@ -302,20 +639,26 @@ func (h *Hub) broadcastToRoomExcept(roomID uuid.UUID, message []byte, excludeID
 	}
 }
 
+// sendRoomUsers отправляет клиенту подключенных участников комнаты.
+// Пользователи в invisible в списке не видны никому, кроме самих себя.
 func (h *Hub) sendRoomUsers(client *Client, roomID uuid.UUID) {
-	users := make([]uuid.UUID, 0)
+	others := make([]uuid.UUID, 0)
 
 	if room, ok := h.rooms[roomID]; ok {
 		userMap := make(map[uuid.UUID]bool)
 		for _, c := range room {
-			userMap[c.UserID] = true
+			if c.UserID != client.UserID && !h.isInvisibleLocal(c) {
+				userMap[c.UserID] = true
+			}
 		}
 
 		for userID := range userMap {
-			users = append(users, userID)
+			others = append(others, userID)
 		}
 	}
 
+	users := append([]uuid.UUID{client.UserID}, others...)
+
 	msg := Message{
 		Type:      TypeRoomUsers,
 		RoomID:    &roomID,
@@ -326,31 +669,109 @@ func (h *Hub) sendRoomUsers(client *Client, roomID uuid.UUID) {
 	if data, err := json.Marshal(users); err == nil {
 		msg.Data = data
 		if msgData, err := json.Marshal(msg); err == nil {
-			select {
-			case client.Send <- msgData:
-			default:
+			if err := client.enqueue(msgData); err == ErrClientQueueFull {
 				log.Printf("Failed to send room users to client %s", client.ID)
 			}
 		}
 	}
 }
 
-// notifyUserStatus уведомляет о статусе пользователя
+// notifyUserStatus уведомляет контактов о подключении или отключении пользователя
 func (h *Hub) notifyUserStatus(userID uuid.UUID, status MessageType) {
-	msg := Message{
-		Type:      status,
-		UserID:    userID,
-		Timestamp: time.Now(),
-	}
+	h.queueConnection(userID, status)
+}
 
-	if data, err := json.Marshal(msg); err == nil {
-		// TODO: отправлять только друзьям или контактам
-		for _, client := range h.clients {
-			select {
-			case client.Send <- data:
-			default:
-			}
+// deliverEnvelope доставляет событие другого инстанса локальным клиентам
+func (h *Hub) deliverEnvelope(env *clusterEnvelope) {
+	switch env.Kind {
+	case envelopeRoom:
+		if env.RoomID == nil {
+			return
+		}
+		excludeID, senderID := uuid.Nil, uuid.Nil
+		if env.Exclude != nil {
+			excludeID = *env.Exclude
+		}
+		if env.SenderID != nil {
+			senderID = *env.SenderID
+		}
+
+		h.mu.RLock()
+		h.broadcastToRoomLocal(*env.RoomID, env.Payload, excludeID, senderID)
+		h.mu.RUnlock()
+
+	case envelopeThread:
+		if env.ThreadID == nil || env.RoomID == nil {
+			return
+		}
+		senderID := uuid.Nil
+		if env.SenderID != nil {
+			senderID = *env.SenderID
+		}
+
+		h.mu.RLock()
+		h.sendToThreadLocal(*env.RoomID, *env.ThreadID, senderID, env.Payload)
+		h.mu.RUnlock()
+
+	case envelopeUser:
+		if env.UserID == nil {
+			return
+		}
+
+		h.mu.RLock()
+		h.sendToUserLocal(*env.UserID, env.Payload)
+		h.mu.RUnlock()
+
+	case envelopePresence:
+		if env.UserID != nil {
+			h.notifyUserStatus(*env.UserID, env.Status)
+		}
+
+	case envelopeStatus:
+		if env.UserID == nil {
+			return
+		}
+
+		h.queueStatus(*env.UserID, env.Payload)
+
+	case envelopeContacts:
+		h.contacts.forget(env.UserIDs...)
+
+	case envelopeBlock:
+		if env.UserID == nil || env.SenderID == nil {
+			return
+		}
+
+		h.mu.Lock()
+		h.updateBlockLocal(*env.UserID, *env.SenderID, env.Blocked)
+		h.mu.Unlock()
+
+	case envelopeUnsubscribe:
+		if env.RoomID == nil || env.UserID == nil {
+			return
+		}
+
+		h.mu.Lock()
+		h.removeUserFromRoomLocal(*env.UserID, *env.RoomID)
+		h.mu.Unlock()
+
+	case envelopeInvisible:
+		if env.UserID == nil {
+			return
 		}
+
+		h.mu.Lock()
+		h.setInvisibleLocal(*env.UserID, env.Invisible)
+		h.mu.Unlock()
+
+	case envelopeSession:
+		if env.UserID == nil {
+			return
+		}
+
+		h.mu.RLock()
+		h.closeSessionsLocal(*env.UserID, env.SessionID, env.Others)
+		h.mu.RUnlock()
 	}
 }
 
@@ -365,31 +786,64 @@ func (h *Hub) ping() {
 
 	if data, err := json.Marshal(msg); err == nil {
 		for _, client := range h.clients {
-			select {
-			case client.Send <- data:
-			default:
-			}
+			client.enqueue(data)
 		}
 	}
 }
 
 // GetOnlineUsers возвращает список онлайн пользователей
 func (h *Hub) GetOnlineUsers() []uuid.UUID {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+	if h.cluster != nil {
+		users, err := h.cluster.onlineUsers()
+		if err == nil {
+			return h.filterVisible(users)
+		}
+		log.Printf("Cluster online users error: %v", err)
+	}
 
+	h.mu.RLock()
 	users := make([]uuid.UUID, 0, len(h.userClients))
 	for userID := range h.userClients {
 		users = append(users, userID)
 	}
-	return users
+	h.mu.RUnlock()
+
+	return h.filterVisible(users)
 }
 
-// GetRoomUsers возвращает список пользователей в комнате
-func (h *Hub) GetRoomUsers(roomID uuid.UUID) []uuid.UUID {
+// OfflineUsers возвращает пользователей из userIDs без соединений ни на одном инстансе
+func (h *Hub) OfflineUsers(userIDs []uuid.UUID) []uuid.UUID {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
+	candidates := make([]uuid.UUID, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if _, ok := h.userClients[userID]; !ok {
+			candidates = append(candidates, userID)
+		}
+	}
+	h.mu.RUnlock()
 
+	if h.cluster == nil || len(candidates) == 0 {
+		return candidates
+	}
+
+	online, err := h.cluster.areOnline(candidates)
+	if err != nil {
+		log.Printf("Cluster presence lookup error: %v", err)
+		return candidates
+	}
+
+	offline := make([]uuid.UUID, 0, len(candidates))
+	for i, userID := range candidates {
+		if !online[i] {
+			offline = append(offline, userID)
+		}
+	}
+	return offline
+}
+
+// GetRoomUsers возвращает список видимых онлайн пользователей в комнате
+func (h *Hub) GetRoomUsers(roomID uuid.UUID) []uuid.UUID {
+	h.mu.RLock()
 	userMap := make(map[uuid.UUID]bool)
 	if room, ok := h.rooms[roomID]; ok {
 		for _, client := range room {
@@ -401,5 +855,7 @@ func (h *Hub) GetRoomUsers(roomID uuid.UUID) []uuid.UUID {
 	for userID := range userMap {
 		users = append(users, userID)
 	}
-	return users
+	h.mu.RUnlock()
+
+	return h.filterVisible(users)
 }
@@ -0,0 +1,422 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Сколько тест ждет ожидаемого события и сколько — чтобы убедиться, что события нет
+const (
+	expectTimeout  = 2 * time.Second
+	silenceTimeout = 200 * time.Millisecond
+)
+
+type nopMessageHandler struct{}
+
+func (nopMessageHandler) HandleMessage(client *Client, msg *Message) error {
+	return nil
+}
+
+// testHub запущенный hub с HTTP сервером, который подключает клиентов
+// как HandleWebSocket: ?user=<id>&rooms=<id>,<id>
+type testHub struct {
+	hub *Hub
+	srv *httptest.Server
+
+	// Серверные клиенты в порядке подключения
+	clients chan *Client
+}
+
+func newTestHub(t *testing.T) *testHub {
+	t.Helper()
+
+	hub := NewHub()
+	go hub.Run()
+
+	clients := make(chan *Client, 16)
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.URL.Query().Get("user"))
+		if err != nil {
+			http.Error(w, "invalid user", http.StatusBadRequest)
+			return
+		}
+
+		var roomIDs []uuid.UUID
+		if rooms := r.URL.Query().Get("rooms"); rooms != "" {
+			for _, s := range strings.Split(rooms, ",") {
+				roomIDs = append(roomIDs, uuid.MustParse(s))
+			}
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		client := NewClient(hub, conn, userID, r.URL.Query().Get("session"))
+		hub.Register(client)
+		hub.SubscribeRooms(client, roomIDs)
+
+		select {
+		case clients <- client:
+		default:
+		}
+
+		go client.WritePump()
+		go client.ReadPump(nopMessageHandler{})
+	}))
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), expectTimeout)
+		defer cancel()
+		hub.Shutdown(ctx)
+		srv.Close()
+	})
+
+	return &testHub{hub: hub, srv: srv, clients: clients}
+}
+
+// testConn соединение тестового клиента. Кадры читаются в отдельной горутине:
+// после истечения read deadline gorilla не дает читать дальше.
+type testConn struct {
+	conn   *websocket.Conn
+	frames chan Message
+}
+
+func (th *testHub) connect(t *testing.T, userID uuid.UUID, roomIDs ...uuid.UUID) *testConn {
+	t.Helper()
+
+	return th.connectSession(t, userID, "", roomIDs...)
+}
+
+// connectSession подключает пользователя с токеном сессии sessionID
+func (th *testHub) connectSession(t *testing.T, userID uuid.UUID, sessionID string, roomIDs ...uuid.UUID) *testConn {
+	t.Helper()
+
+	rooms := make([]string, len(roomIDs))
+	for i, roomID := range roomIDs {
+		rooms[i] = roomID.String()
+	}
+
+	url := "ws" + strings.TrimPrefix(th.srv.URL, "http") + "?user=" + userID.String() + "&session=" + sessionID + "&rooms=" + strings.Join(rooms, ",")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	tc := &testConn{conn: conn, frames: make(chan Message, 64)}
+	go func() {
+		defer close(tc.frames)
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			tc.frames <- msg
+		}
+	}()
+
+	// Список участников приходит после подписки на каждую комнату
+	for range roomIDs {
+		tc.expect(t, TypeRoomUsers)
+	}
+	return tc
+}
+
+// expect ждет событие msgType, пропуская остальные
+func (tc *testConn) expect(t *testing.T, msgType MessageType) Message {
+	t.Helper()
+
+	timeout := time.After(expectTimeout)
+	for {
+		select {
+		case msg, ok := <-tc.frames:
+			if !ok {
+				t.Fatalf("connection closed while waiting for %s", msgType)
+			}
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", msgType)
+		}
+	}
+}
+
+// expectNone проверяет, что событие msgType не приходит
+func (tc *testConn) expectNone(t *testing.T, msgType MessageType) {
+	t.Helper()
+
+	timeout := time.After(silenceTimeout)
+	for {
+		select {
+		case msg, ok := <-tc.frames:
+			if !ok {
+				return
+			}
+			if msg.Type == msgType {
+				t.Fatalf("unexpected %s: %s", msgType, msg.Data)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// expectClosed ждет закрытия соединения сервером с кодом code
+func (tc *testConn) expectClosed(t *testing.T, code int) {
+	t.Helper()
+
+	// Читатель завершается на close frame
+	for range tc.frames {
+	}
+
+	tc.conn.SetReadDeadline(time.Now().Add(expectTimeout))
+	_, _, err := tc.conn.ReadMessage()
+	if !websocket.IsCloseError(err, code) {
+		t.Fatalf("expected close %d, got %v", code, err)
+	}
+}
+
+func encodeTestMessage(t *testing.T, msgType MessageType, roomID uuid.UUID, senderID uuid.UUID, text string) []byte {
+	t.Helper()
+
+	data, _ := json.Marshal(map[string]string{"content": text})
+	msg, err := json.Marshal(Message{Type: msgType, RoomID: &roomID, UserID: senderID, Data: data, Timestamp: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestSendToRoomReachesOnlySubscribers(t *testing.T) {
+	th := newTestHub(t)
+	roomID := uuid.New()
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	aliceConn := th.connect(t, alice, roomID)
+	bobConn := th.connect(t, bob, roomID)
+	carolConn := th.connect(t, carol)
+
+	th.hub.SendToRoom(roomID, encodeTestMessage(t, TypeMessage, roomID, alice, "hello"))
+
+	for _, tc := range []*testConn{aliceConn, bobConn} {
+		msg := tc.expect(t, TypeMessage)
+		if msg.RoomID == nil || *msg.RoomID != roomID {
+			t.Fatalf("message delivered for wrong room: %v", msg.RoomID)
+		}
+		if msg.Seq == 0 {
+			t.Fatalf("room message must be sequenced for resume")
+		}
+	}
+	carolConn.expectNone(t, TypeMessage)
+}
+
+func TestRoomJoinNotifiesExistingMembers(t *testing.T) {
+	th := newTestHub(t)
+	roomID := uuid.New()
+	alice, bob := uuid.New(), uuid.New()
+
+	aliceConn := th.connect(t, alice, roomID)
+	th.connect(t, bob, roomID)
+
+	msg := aliceConn.expect(t, TypeRoomJoin)
+	if msg.UserID != bob {
+		t.Fatalf("expected room_join of %s, got %s", bob, msg.UserID)
+	}
+}
+
+func TestSendToRoomFromSkipsBlockedRecipients(t *testing.T) {
+	th := newTestHub(t)
+	roomID := uuid.New()
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	// bob заблокировал alice
+	th.hub.SetUserBlocks(bob, []uuid.UUID{alice}, nil)
+	th.hub.SetUserBlocks(alice, nil, []uuid.UUID{bob})
+
+	aliceConn := th.connect(t, alice, roomID)
+	bobConn := th.connect(t, bob, roomID)
+	carolConn := th.connect(t, carol, roomID)
+
+	th.hub.SendToRoomFrom(roomID, alice, encodeTestMessage(t, TypeMessage, roomID, alice, "hi"))
+
+	aliceConn.expect(t, TypeMessage)
+	carolConn.expect(t, TypeMessage)
+	bobConn.expectNone(t, TypeMessage)
+
+	// В общей комнате фильтр односторонний: alice видит сообщения bob
+	th.hub.SendToRoomFrom(roomID, bob, encodeTestMessage(t, TypeMessage, roomID, bob, "hey"))
+	aliceConn.expect(t, TypeMessage)
+}
+
+func TestSendToUserReachesAllConnections(t *testing.T) {
+	th := newTestHub(t)
+	alice := uuid.New()
+
+	first := th.connect(t, alice)
+	second := th.connect(t, alice)
+
+	th.hub.SendToUser(alice, encodeTestMessage(t, TypeFriendAdded, uuid.Nil, alice, ""))
+
+	first.expect(t, TypeFriendAdded)
+	second.expect(t, TypeFriendAdded)
+}
+
+func TestShutdownClosesClientsWithRestartCode(t *testing.T) {
+	th := newTestHub(t)
+	tc := th.connect(t, uuid.New())
+
+	ctx, cancel := context.WithTimeout(context.Background(), expectTimeout)
+	defer cancel()
+	if err := th.hub.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	tc.expectClosed(t, websocket.CloseServiceRestart)
+}
+
+func TestInvisibleUserHiddenInRoom(t *testing.T) {
+	th := newTestHub(t)
+	roomID := uuid.New()
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	if _, err := th.hub.SetPresence(bob, PresenceUpdate{Status: StatusInvisible}); err != nil {
+		t.Fatalf("SetPresence: %v", err)
+	}
+
+	aliceConn := th.connect(t, alice, roomID)
+	th.connect(t, bob, roomID)
+	aliceConn.expectNone(t, TypeRoomJoin)
+
+	// carol подписываем вручную, чтобы прочитать ее room_users; alice и bob уже в канале
+	carolConn := th.connect(t, carol)
+	<-th.clients
+	<-th.clients
+	th.hub.JoinRoom(<-th.clients, roomID)
+
+	var users []uuid.UUID
+	if err := json.Unmarshal(carolConn.expect(t, TypeRoomUsers).Data, &users); err != nil {
+		t.Fatalf("decode room_users: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("expected carol and alice in room_users, got %v", users)
+	}
+	for _, userID := range users {
+		if userID == bob {
+			t.Fatal("invisible user listed in room_users")
+		}
+	}
+}
+
+// Переход в invisible после подключения скрывает и выход из комнаты
+func TestInvisibleAfterConnectHidesRoomLeave(t *testing.T) {
+	th := newTestHub(t)
+	roomID := uuid.New()
+	alice, bob := uuid.New(), uuid.New()
+
+	aliceConn := th.connect(t, alice, roomID)
+	th.connect(t, bob, roomID)
+	aliceConn.expect(t, TypeRoomJoin)
+
+	<-th.clients
+	bobClient := <-th.clients
+
+	if _, err := th.hub.SetPresence(bob, PresenceUpdate{Status: StatusInvisible}); err != nil {
+		t.Fatalf("SetPresence: %v", err)
+	}
+	th.hub.LeaveRoom(bobClient, roomID)
+	aliceConn.expectNone(t, TypeRoomLeave)
+}
+
+// staticContacts контакты пользователей для тестов
+type staticContacts map[uuid.UUID][]uuid.UUID
+
+func (s staticContacts) GetContactIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	return s[userID], nil
+}
+
+// staticBlocks блокировки для тестов: кто -> кого заблокировал
+type staticBlocks map[uuid.UUID][]uuid.UUID
+
+func (s staticBlocks) GetBlockedIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	return s[userID], nil
+}
+
+func (s staticBlocks) GetBlockerIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0)
+	for blockerID, blocked := range s {
+		for _, id := range blocked {
+			if id == userID {
+				ids = append(ids, blockerID)
+			}
+		}
+	}
+	return ids, nil
+}
+
+func TestGetPresenceHidesBlockedUsersFromRESTViewer(t *testing.T) {
+	th := newTestHub(t)
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	// alice запрашивает статусы через REST без соединения; bob ее заблокировал
+	th.hub.SetContactSource(staticContacts{alice: {bob, carol}, bob: {alice}, carol: {alice}})
+	th.hub.SetBlockSource(staticBlocks{bob: {alice}})
+
+	th.connect(t, bob)
+	th.connect(t, carol)
+
+	// Соединения регистрируются по порядку: раз carol онлайн, то и bob
+	deadline := time.Now().Add(expectTimeout)
+	for th.hub.GetPresence(alice, []uuid.UUID{carol})[0].Status != StatusOnline {
+		if time.Now().After(deadline) {
+			t.Fatal("carol never became visible to alice")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status := th.hub.GetPresence(alice, []uuid.UUID{bob})[0].Status; status != StatusOffline {
+		t.Fatalf("blocked user must look offline, got %s", status)
+	}
+}
+
+func TestCloseSessionDisconnectsOnlyItsConnections(t *testing.T) {
+	th := newTestHub(t)
+	alice := uuid.New()
+
+	revoked := th.connectSession(t, alice, "phone")
+	kept := th.connectSession(t, alice, "laptop")
+
+	th.hub.CloseSession(alice, "phone")
+	revoked.expectClosed(t, CloseSessionRevoked)
+
+	th.hub.SendToUser(alice, encodeTestMessage(t, TypeFriendAdded, uuid.Nil, alice, ""))
+	kept.expect(t, TypeFriendAdded)
+}
+
+func TestCloseOtherSessionsKeepsCurrent(t *testing.T) {
+	th := newTestHub(t)
+	alice := uuid.New()
+
+	current := th.connectSession(t, alice, "laptop")
+	phone := th.connectSession(t, alice, "phone")
+	tablet := th.connectSession(t, alice, "tablet")
+
+	th.hub.CloseOtherSessions(alice, "laptop")
+	phone.expectClosed(t, CloseSessionRevoked)
+	tablet.expectClosed(t, CloseSessionRevoked)
+
+	th.hub.SendToUser(alice, encodeTestMessage(t, TypeFriendAdded, uuid.Nil, alice, ""))
+	current.expect(t, TypeFriendAdded)
+}
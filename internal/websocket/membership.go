@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Сколько хранится подтвержденное членство. Исключение из комнаты сбрасывает запись сразу.
+const membershipCacheTTL = time.Minute
+
+// MembershipChecker проверяет членство пользователя в комнате
+type MembershipChecker interface {
+	IsRoomMember(userID, roomID string) (bool, error)
+}
+
+type membershipKey struct {
+	userID uuid.UUID
+	roomID uuid.UUID
+}
+
+// membershipCache кэш подтвержденного членства. Отказы не кэшируются,
+// чтобы только что вступивший пользователь мог сразу подписаться.
+type membershipCache struct {
+	mu      sync.Mutex
+	entries map[membershipKey]time.Time
+}
+
+func newMembershipCache() *membershipCache {
+	return &membershipCache{entries: make(map[membershipKey]time.Time)}
+}
+
+func (c *membershipCache) get(userID, roomID uuid.UUID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := membershipKey{userID: userID, roomID: roomID}
+	expires, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(c.entries, key)
+		return false
+	}
+	return true
+}
+
+func (c *membershipCache) set(userID, roomID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[membershipKey{userID: userID, roomID: roomID}] = time.Now().Add(membershipCacheTTL)
+}
+
+func (c *membershipCache) invalidate(userID, roomID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, membershipKey{userID: userID, roomID: roomID})
+}
+
+// SetMembershipChecker задает проверку членства для подписки на комнаты. Вызывается до Run.
+// Без нее подписка по запросу клиента запрещена.
+func (h *Hub) SetMembershipChecker(checker MembershipChecker) {
+	h.membership = checker
+}
+
+// authorizeRoom проверяет, что пользователь состоит в комнате
+func (h *Hub) authorizeRoom(userID, roomID uuid.UUID) error {
+	if h.members.get(userID, roomID) {
+		return nil
+	}
+
+	if h.membership == nil {
+		return ErrUserNotInRoom
+	}
+
+	isMember, err := h.membership.IsRoomMember(userID.String(), roomID.String())
+	if err != nil {
+		log.Printf("Membership check error: %v", err)
+		return ErrMembershipCheck
+	}
+	if !isMember {
+		return ErrUserNotInRoom
+	}
+
+	h.members.set(userID, roomID)
+	return nil
+}
+
+// JoinRoomAuthorized подписывает клиента на комнату, только если пользователь в ней состоит
+func (h *Hub) JoinRoomAuthorized(client *Client, roomID uuid.UUID) error {
+	if err := h.authorizeRoom(client.UserID, roomID); err != nil {
+		return err
+	}
+
+	h.JoinRoom(client, roomID)
+	return nil
+}
+
+// SubscribeRooms подписывает только что подключенного клиента на его комнаты,
+// членство в которых уже проверено по базе
+func (h *Hub) SubscribeRooms(client *Client, roomIDs []uuid.UUID) {
+	for _, roomID := range roomIDs {
+		h.members.set(client.UserID, roomID)
+		h.JoinRoom(client, roomID)
+	}
+}
+
+// handleRoomJoin обрабатывает room_join клиента и отвечает подтверждением или ошибкой
+func (c *Client) handleRoomJoin(roomID uuid.UUID) {
+	if err := c.Hub.JoinRoomAuthorized(c, roomID); err != nil {
+		c.sendRoomError(roomID, err)
+		return
+	}
+
+	msg := Message{
+		Type:      TypeRoomJoined,
+		RoomID:    &roomID,
+		UserID:    c.UserID,
+		Timestamp: time.Now(),
+	}
+
+	if data, err := json.Marshal(msg); err == nil {
+		c.enqueue(data)
+	}
+}
+
+// sendRoomError отправляет ошибку, относящуюся к конкретной комнате
+func (c *Client) sendRoomError(roomID uuid.UUID, err error) {
+	msg := Message{
+		Type:      TypeError,
+		RoomID:    &roomID,
+		UserID:    c.UserID,
+		Timestamp: time.Now(),
+	}
+
+	if data, mErr := json.Marshal(map[string]string{"error": err.Error()}); mErr == nil {
+		msg.Data = data
+		if msgData, mErr := json.Marshal(msg); mErr == nil {
+			c.enqueue(msgData)
+		}
+	}
+}
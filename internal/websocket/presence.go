@@ -0,0 +1,359 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// PresenceStatus статус пользователя. Invisible видит только сам пользователь:
+// остальным он показывается как offline.
+type PresenceStatus string
+
+const (
+	StatusOnline    PresenceStatus = "online"
+	StatusIdle      PresenceStatus = "idle"
+	StatusDND       PresenceStatus = "dnd"
+	StatusInvisible PresenceStatus = "invisible"
+	StatusOffline   PresenceStatus = "offline"
+)
+
+const (
+	// Через сколько без активности во всех соединениях пользователь становится idle
+	idleTimeout = 5 * time.Minute
+
+	// Как часто проверять неактивность
+	idleSweepPeriod = 30 * time.Second
+
+	// Активность соединения записывается в хранилище не чаще этого интервала
+	activityTouchInterval = 30 * time.Second
+
+	// Максимальная длина пользовательского статуса
+	maxCustomStatusLength = 128
+)
+
+var (
+	ErrInvalidStatus      = errors.New("invalid status")
+	ErrCustomStatusLength = errors.New("custom status is too long")
+	ErrCustomStatusExpiry = errors.New("custom status expiry must be in the future")
+)
+
+// Presence статус пользователя, как его видят другие
+type Presence struct {
+	UserID                uuid.UUID      `json:"user_id"`
+	Status                PresenceStatus `json:"status"`
+	CustomStatus          string         `json:"custom_status,omitempty"`
+	CustomStatusExpiresAt *time.Time     `json:"custom_status_expires_at,omitempty"`
+}
+
+// PresenceUpdate новый статус от пользователя. Пустой CustomStatus снимает пользовательский статус.
+type PresenceUpdate struct {
+	Status                PresenceStatus `json:"status"`
+	CustomStatus          string         `json:"custom_status"`
+	CustomStatusExpiresAt *time.Time     `json:"custom_status_expires_at"`
+}
+
+// Validate проверяет статус и пользовательский текст
+func (u *PresenceUpdate) Validate() error {
+	switch u.Status {
+	case StatusOnline, StatusIdle, StatusDND, StatusInvisible:
+	default:
+		return ErrInvalidStatus
+	}
+
+	if utf8.RuneCountInString(u.CustomStatus) > maxCustomStatusLength {
+		return ErrCustomStatusLength
+	}
+	if u.CustomStatusExpiresAt != nil && !u.CustomStatusExpiresAt.After(time.Now()) {
+		return ErrCustomStatusExpiry
+	}
+	return nil
+}
+
+// visiblePresence вычисляет статус, который видят другие пользователи
+func visiblePresence(userID uuid.UUID, state presenceState, connected bool, now time.Time) Presence {
+	presence := Presence{UserID: userID, Status: StatusOffline}
+	if !connected || state.Status == StatusInvisible {
+		return presence
+	}
+
+	switch {
+	case state.Status == StatusDND || state.Status == StatusIdle:
+		presence.Status = state.Status
+	case state.AutoIdle:
+		presence.Status = StatusIdle
+	default:
+		presence.Status = StatusOnline
+	}
+
+	if state.CustomStatus != "" && (state.CustomExpiresAt == nil || state.CustomExpiresAt.After(now)) {
+		presence.CustomStatus = state.CustomStatus
+		presence.CustomStatusExpiresAt = state.CustomExpiresAt
+	}
+	return presence
+}
+
+// GetPresence возвращает статусы пользователей так, как их видит viewerID.
+// Статус тех, кто не входит в контакты viewerID или связан с ним блокировкой, всегда offline.
+func (h *Hub) GetPresence(viewerID uuid.UUID, userIDs []uuid.UUID) []Presence {
+	states, err := h.presence.loadStatuses(userIDs)
+	if err != nil {
+		log.Printf("Presence load error: %v", err)
+		states = map[uuid.UUID]presenceState{}
+	}
+
+	offline := make(map[uuid.UUID]bool)
+	for _, userID := range h.OfflineUsers(userIDs) {
+		offline[userID] = true
+	}
+
+	blocked := h.blockedEitherOf(viewerID)
+
+	now := time.Now()
+	result := make([]Presence, len(userIDs))
+	for i, userID := range userIDs {
+		if !h.canSeePresence(viewerID, userID, blocked) {
+			result[i] = Presence{UserID: userID, Status: StatusOffline}
+			continue
+		}
+		result[i] = visiblePresence(userID, states[userID], !offline[userID], now)
+	}
+	return result
+}
+
+// SetPresence сохраняет статус пользователя и рассылает его изменение.
+// Переход в invisible и обратно выглядит для других как отключение и подключение.
+func (h *Hub) SetPresence(userID uuid.UUID, update PresenceUpdate) (Presence, error) {
+	if err := update.Validate(); err != nil {
+		return Presence{}, err
+	}
+
+	states, err := h.presence.loadStatuses([]uuid.UUID{userID})
+	if err != nil {
+		return Presence{}, err
+	}
+	old := states[userID]
+
+	state := presenceState{
+		Status:          update.Status,
+		CustomStatus:    update.CustomStatus,
+		CustomExpiresAt: update.CustomStatusExpiresAt,
+		AutoIdle:        old.AutoIdle,
+	}
+	if state.CustomStatus == "" {
+		state.CustomExpiresAt = nil
+	}
+
+	if err := h.presence.saveStatus(userID, state); err != nil {
+		return Presence{}, err
+	}
+	h.setInvisible(userID, state.Status == StatusInvisible)
+
+	connected := len(h.OfflineUsers([]uuid.UUID{userID})) == 0
+	presence := visiblePresence(userID, state, connected, time.Now())
+	if !connected {
+		return presence, nil
+	}
+
+	wasInvisible := old.Status == StatusInvisible
+	switch {
+	case state.Status == StatusInvisible && !wasInvisible:
+		h.broadcastPresence(userID, TypeUserOffline)
+	case state.Status != StatusInvisible && wasInvisible:
+		h.broadcastPresence(userID, TypeUserOnline)
+		h.publishStatus(presence)
+	case state.Status != StatusInvisible:
+		h.publishStatus(presence)
+	}
+
+	return presence, nil
+}
+
+// isInvisible сообщает, что пользователь скрывает свое присутствие
+func (h *Hub) isInvisible(userID uuid.UUID) bool {
+	return isInvisibleIn(h.presence, userID)
+}
+
+// isInvisibleIn проверяет статус invisible в хранилище; при ошибке пользователь считается видимым
+func isInvisibleIn(store presenceStore, userID uuid.UUID) bool {
+	states, err := store.loadStatuses([]uuid.UUID{userID})
+	if err != nil {
+		log.Printf("Presence load error: %v", err)
+		return false
+	}
+	return states[userID].Status == StatusInvisible
+}
+
+// setInvisible обновляет кэш режима invisible на всех инстансах
+func (h *Hub) setInvisible(userID uuid.UUID, invisible bool) {
+	h.mu.Lock()
+	h.setInvisibleLocal(userID, invisible)
+	h.mu.Unlock()
+
+	if h.cluster != nil {
+		h.cluster.publish(&clusterEnvelope{
+			Kind:      envelopeInvisible,
+			UserID:    &userID,
+			Invisible: invisible,
+		})
+	}
+}
+
+// setInvisibleLocal обновляет кэш, только если пользователь подключен к этому инстансу.
+// Вызывающий должен держать h.mu на запись.
+func (h *Hub) setInvisibleLocal(userID uuid.UUID, invisible bool) {
+	if _, ok := h.invisible[userID]; ok {
+		h.invisible[userID] = invisible
+	}
+}
+
+// isInvisibleLocal проверяет режим invisible владельца соединения по кэшу, без обращения
+// к хранилищу. Подписка на комнаты может опередить регистрацию, тогда берется статус,
+// прочитанный при подключении. Вызывающий должен держать h.mu.
+func (h *Hub) isInvisibleLocal(client *Client) bool {
+	if invisible, ok := h.invisible[client.UserID]; ok {
+		return invisible
+	}
+	return client.invisible
+}
+
+// filterVisible убирает из списка пользователей в invisible
+func (h *Hub) filterVisible(userIDs []uuid.UUID) []uuid.UUID {
+	if len(userIDs) == 0 {
+		return userIDs
+	}
+
+	states, err := h.presence.loadStatuses(userIDs)
+	if err != nil {
+		log.Printf("Presence load error: %v", err)
+		return userIDs
+	}
+
+	visible := make([]uuid.UUID, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if states[userID].Status != StatusInvisible {
+			visible = append(visible, userID)
+		}
+	}
+	return visible
+}
+
+// broadcastPresence рассылает user_online/user_offline на всех инстансах
+func (h *Hub) broadcastPresence(userID uuid.UUID, status MessageType) {
+	h.notifyUserStatus(userID, status)
+
+	if h.cluster != nil {
+		h.cluster.publish(&clusterEnvelope{
+			Kind:   envelopePresence,
+			UserID: &userID,
+			Status: status,
+		})
+	}
+}
+
+// publishStatus рассылает user_status со статусом пользователя на всех инстансах
+func (h *Hub) publishStatus(presence Presence) {
+	msg := Message{
+		Type:      TypeUserStatus,
+		UserID:    presence.UserID,
+		Timestamp: time.Now(),
+	}
+
+	data, err := json.Marshal(presence)
+	if err != nil {
+		return
+	}
+	msg.Data = data
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	h.queueStatus(presence.UserID, payload)
+
+	if h.cluster != nil {
+		userID := presence.UserID
+		h.cluster.publish(&clusterEnvelope{
+			Kind:    envelopeStatus,
+			UserID:  &userID,
+			Payload: payload,
+		})
+	}
+}
+
+// touch отмечает активность клиента; вернувшийся из idle пользователь снова online
+func (h *Hub) touch(client *Client) {
+	now := time.Now()
+	last := client.lastTouch.Load()
+	if now.Sub(time.UnixMilli(last)) < activityTouchInterval {
+		return
+	}
+	if !client.lastTouch.CompareAndSwap(last, now.UnixMilli()) {
+		return
+	}
+
+	wasIdle, err := h.presence.touchActivity(client.UserID, now)
+	if err != nil {
+		log.Printf("Presence activity error: %v", err)
+		return
+	}
+	if wasIdle {
+		h.publishCurrentStatus(client.UserID)
+	}
+}
+
+// runIdleSweep периодически переводит в idle пользователей без активности
+func (h *Hub) runIdleSweep() {
+	ticker := time.NewTicker(idleSweepPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+			h.sweepIdle()
+		}
+	}
+}
+
+func (h *Hub) sweepIdle() {
+	h.mu.RLock()
+	userIDs := make([]uuid.UUID, 0, len(h.userClients))
+	for userID := range h.userClients {
+		userIDs = append(userIDs, userID)
+	}
+	h.mu.RUnlock()
+
+	cutoff := time.Now().Add(-idleTimeout)
+	for _, userID := range userIDs {
+		idle, err := h.presence.markIdle(userID, cutoff)
+		if err != nil {
+			log.Printf("Presence idle error: %v", err)
+			continue
+		}
+		if idle {
+			h.publishCurrentStatus(userID)
+		}
+	}
+}
+
+// publishCurrentStatus рассылает текущий статус, если автоматический idle на него влияет
+func (h *Hub) publishCurrentStatus(userID uuid.UUID) {
+	states, err := h.presence.loadStatuses([]uuid.UUID{userID})
+	if err != nil {
+		log.Printf("Presence load error: %v", err)
+		return
+	}
+
+	state := states[userID]
+	if state.Status != "" && state.Status != StatusOnline {
+		return
+	}
+	h.publishStatus(visiblePresence(userID, state, true, time.Now()))
+}
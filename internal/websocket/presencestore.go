@@ -0,0 +1,188 @@
+package websocket
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Hash статуса пользователя: status, text, expires (unix), idle (0/1), active (unix ms)
+const statusKeyPrefix = "ws:status:"
+
+// presenceState сохраненный статус пользователя
+type presenceState struct {
+	Status          PresenceStatus
+	CustomStatus    string
+	CustomExpiresAt *time.Time
+
+	// Все соединения пользователя давно неактивны
+	AutoIdle bool
+}
+
+// presenceStore хранит выбранный пользователем статус и признак автоматического idle
+type presenceStore interface {
+	loadStatuses(userIDs []uuid.UUID) (map[uuid.UUID]presenceState, error)
+	saveStatus(userID uuid.UUID, state presenceState) error
+
+	// touchActivity отмечает активность; возвращает true, если пользователь вышел из idle
+	touchActivity(userID uuid.UUID, now time.Time) (bool, error)
+
+	// markIdle переводит в idle, если активности не было с cutoff; true, если статус изменился
+	markIdle(userID uuid.UUID, cutoff time.Time) (bool, error)
+}
+
+// memoryPresenceStore хранилище статусов для работы в одном инстансе
+type memoryPresenceStore struct {
+	mu       sync.Mutex
+	states   map[uuid.UUID]presenceState
+	activity map[uuid.UUID]time.Time
+}
+
+func newMemoryPresenceStore() *memoryPresenceStore {
+	return &memoryPresenceStore{
+		states:   make(map[uuid.UUID]presenceState),
+		activity: make(map[uuid.UUID]time.Time),
+	}
+}
+
+func (s *memoryPresenceStore) loadStatuses(userIDs []uuid.UUID) (map[uuid.UUID]presenceState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[uuid.UUID]presenceState, len(userIDs))
+	for _, userID := range userIDs {
+		if state, ok := s.states[userID]; ok {
+			result[userID] = state
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryPresenceStore) saveStatus(userID uuid.UUID, state presenceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.AutoIdle = s.states[userID].AutoIdle
+	s.states[userID] = state
+	return nil
+}
+
+func (s *memoryPresenceStore) touchActivity(userID uuid.UUID, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.activity[userID] = now
+
+	state := s.states[userID]
+	if !state.AutoIdle {
+		return false, nil
+	}
+	state.AutoIdle = false
+	s.states[userID] = state
+	return true, nil
+}
+
+func (s *memoryPresenceStore) markIdle(userID uuid.UUID, cutoff time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.states[userID]
+	if state.AutoIdle || !s.activity[userID].Before(cutoff) {
+		return false, nil
+	}
+	state.AutoIdle = true
+	s.states[userID] = state
+	return true, nil
+}
+
+var touchActivityScript = redis.NewScript(`
+redis.call('HSET', KEYS[1], 'active', ARGV[1])
+if redis.call('HGET', KEYS[1], 'idle') == '1' then
+	redis.call('HSET', KEYS[1], 'idle', '0')
+	return 1
+end
+return 0
+`)
+
+var markIdleScript = redis.NewScript(`
+local active = tonumber(redis.call('HGET', KEYS[1], 'active') or '0')
+if active < tonumber(ARGV[1]) and redis.call('HGET', KEYS[1], 'idle') ~= '1' then
+	redis.call('HSET', KEYS[1], 'idle', '1')
+	return 1
+end
+return 0
+`)
+
+// loadStatuses читает статусы из Redis: выбранный статус общий для всех инстансов
+func (c *Cluster) loadStatuses(userIDs []uuid.UUID) (map[uuid.UUID]presenceState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	cmds := make([]*redis.SliceCmd, len(userIDs))
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, userID := range userIDs {
+			cmds[i] = pipe.HMGet(ctx, statusKeyPrefix+userID.String(), "status", "text", "expires", "idle")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uuid.UUID]presenceState, len(userIDs))
+	for i, cmd := range cmds {
+		values := cmd.Val()
+		if values[0] == nil && values[3] == nil {
+			continue
+		}
+
+		status, _ := values[0].(string)
+		state := presenceState{Status: PresenceStatus(status)}
+		state.CustomStatus, _ = values[1].(string)
+		if raw, ok := values[2].(string); ok && raw != "" && raw != "0" {
+			if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				expires := time.Unix(unix, 0)
+				state.CustomExpiresAt = &expires
+			}
+		}
+		state.AutoIdle = values[3] == "1"
+		result[userIDs[i]] = state
+	}
+	return result, nil
+}
+
+func (c *Cluster) saveStatus(userID uuid.UUID, state presenceState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	var expires int64
+	if state.CustomExpiresAt != nil {
+		expires = state.CustomExpiresAt.Unix()
+	}
+
+	return c.rdb.HSet(ctx, statusKeyPrefix+userID.String(),
+		"status", string(state.Status),
+		"text", state.CustomStatus,
+		"expires", expires,
+	).Err()
+}
+
+func (c *Cluster) touchActivity(userID uuid.UUID, now time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	n, err := touchActivityScript.Run(ctx, c.rdb, []string{statusKeyPrefix + userID.String()}, now.UnixMilli()).Int()
+	return n == 1, err
+}
+
+func (c *Cluster) markIdle(userID uuid.UUID, cutoff time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterOpTimeout)
+	defer cancel()
+
+	n, err := markIdleScript.Run(ctx, c.rdb, []string{statusKeyPrefix + userID.String()}, cutoff.UnixMilli()).Int()
+	return n == 1, err
+}
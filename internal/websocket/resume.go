@@ -0,0 +1,204 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Сколько комнат можно возобновить одним resume
+	maxResumeRooms = 100
+
+	// Число полос блокировок нумерации событий комнат
+	roomSeqStripes = 64
+)
+
+// ResumeRequest данные resume: последний увиденный номер события по комнатам.
+// Пропущенные ответы тредов досылаются, если клиент подписался на тред до resume.
+type ResumeRequest struct {
+	Rooms map[uuid.UUID]uint64 `json:"rooms"`
+}
+
+// ResumedEvent ответ на resume по одной комнате. Complete = false означает, что часть
+// пропущенных событий уже вытеснена из журнала и историю нужно догрузить через REST.
+type ResumedEvent struct {
+	LastSeq  uint64 `json:"last_seq"`
+	Replayed int    `json:"replayed"`
+	Complete bool   `json:"complete"`
+}
+
+// roomSeqLock возвращает блокировку нумерации событий комнаты
+func (h *Hub) roomSeqLock(roomID uuid.UUID) *sync.Mutex {
+	return &h.roomSeqMu[int(roomID[len(roomID)-1])%roomSeqStripes]
+}
+
+// sequence присваивает событию комнаты следующий номер и сохраняет его для досылки.
+// Вызывающий держит roomSeqLock комнаты до постановки события в очереди.
+// Если журнал недоступен, событие уходит без номера.
+func (h *Hub) sequence(roomID, senderID, threadID uuid.UUID, message []byte) []byte {
+	seq, err := h.events.append(roomID, senderID, threadID, message)
+	if err != nil {
+		log.Printf("Event log append error: %v", err)
+		return message
+	}
+
+	data, err := withSeq(message, seq)
+	if err != nil {
+		return message
+	}
+	return data
+}
+
+// Resume возобновляет сессию после переподключения: подписывает клиента на комнаты,
+// в которых он состоит, досылает события после последнего увиденного номера и только
+// потом переключает комнату на живую доставку. Живые события, пришедшие во время
+// досылки, придерживаются и отправляются следом без дублей.
+func (h *Hub) Resume(client *Client, req ResumeRequest) {
+	if len(req.Rooms) > maxResumeRooms {
+		client.SendError("too many rooms to resume")
+		return
+	}
+
+	for roomID, lastSeq := range req.Rooms {
+		if err := h.authorizeRoom(client.UserID, roomID); err != nil {
+			client.sendRoomError(roomID, err)
+			continue
+		}
+
+		client.startResume(roomID)
+		h.JoinRoom(client, roomID)
+
+		events, complete, err := h.events.since(roomID, lastSeq)
+		if err != nil {
+			log.Printf("Event log replay error: %v", err)
+			complete = false
+		}
+
+		replayed := 0
+		for _, ev := range h.replayableFor(client, events) {
+			data, err := withSeq(ev.payload, ev.seq)
+			if err != nil {
+				continue
+			}
+			if client.enqueue(data) != nil {
+				break
+			}
+			lastSeq = ev.seq
+			replayed++
+		}
+
+		h.sendResumed(client, roomID, ResumedEvent{
+			LastSeq:  lastSeq,
+			Replayed: replayed,
+			Complete: complete,
+		})
+		client.finishResume(roomID, lastSeq)
+	}
+}
+
+// replayableFor отбирает события для досылки клиенту: без событий пользователей,
+// с которыми есть блокировка в любую сторону, и без ответов тредов, на которые
+// клиент не подписан
+func (h *Hub) replayableFor(client *Client, events []replayEvent) []replayEvent {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+
+	result := make([]replayEvent, 0, len(events))
+	for _, ev := range events {
+		if ev.senderID != uuid.Nil && h.isBlockedEither(client.UserID, ev.senderID) {
+			continue
+		}
+		if ev.threadID != uuid.Nil {
+			if _, ok := client.Threads[ev.threadID]; !ok {
+				continue
+			}
+		}
+		result = append(result, ev)
+	}
+	return result
+}
+
+func (h *Hub) sendResumed(client *Client, roomID uuid.UUID, event ResumedEvent) {
+	msg := Message{
+		Type:      TypeResumed,
+		RoomID:    &roomID,
+		UserID:    client.UserID,
+		Timestamp: time.Now(),
+	}
+
+	if data, err := json.Marshal(event); err == nil {
+		msg.Data = data
+		if msgData, err := json.Marshal(msg); err == nil {
+			client.enqueue(msgData)
+		}
+	}
+}
+
+// enqueueRoom ставит в очередь событие комнаты. Пока по комнате идет досылка,
+// событие придерживается до finishResume.
+func (c *Client) enqueueRoom(roomID uuid.UUID, data []byte) error {
+	c.resumeMu.Lock()
+	defer c.resumeMu.Unlock()
+
+	if pending, ok := c.resuming[roomID]; ok {
+		if len(pending) >= sendQueueSize {
+			c.disconnectSlow()
+			return ErrClientQueueFull
+		}
+		c.resuming[roomID] = append(pending, data)
+		return nil
+	}
+
+	return c.enqueue(data)
+}
+
+func (c *Client) startResume(roomID uuid.UUID) {
+	c.resumeMu.Lock()
+	defer c.resumeMu.Unlock()
+
+	c.resuming[roomID] = make([][]byte, 0)
+}
+
+// finishResume отправляет придержанные события, пропуская уже досланные, и переключает
+// комнату на живую доставку. Все под resumeMu, чтобы новые события не обогнали придержанные.
+func (c *Client) finishResume(roomID uuid.UUID, lastSeq uint64) {
+	c.resumeMu.Lock()
+	defer c.resumeMu.Unlock()
+
+	pending := c.resuming[roomID]
+	delete(c.resuming, roomID)
+
+	for _, data := range pending {
+		if seq := frameSeq(data); seq != 0 && seq <= lastSeq {
+			continue
+		}
+		c.enqueue(data)
+	}
+}
+
+// withSeq добавляет номер события в сообщение
+func withSeq(message []byte, seq uint64) ([]byte, error) {
+	var msg Message
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, err
+	}
+	msg.Seq = seq
+	return json.Marshal(msg)
+}
+
+// frameSeq возвращает номер события из кадра, 0 для событий без номера
+func frameSeq(data []byte) uint64 {
+	var msg struct {
+		Seq uint64 `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return 0
+	}
+	return msg.Seq
+}
@@ -0,0 +1,144 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// resume отправляет resume и возвращает ответ по комнате вместе с досланными событиями
+func (tc *testConn) resume(t *testing.T, roomID uuid.UUID, lastSeq uint64) (ResumedEvent, []Message) {
+	t.Helper()
+
+	data, _ := json.Marshal(ResumeRequest{Rooms: map[uuid.UUID]uint64{roomID: lastSeq}})
+	if err := tc.conn.WriteJSON(Message{Type: TypeResume, Data: data, Timestamp: time.Now()}); err != nil {
+		t.Fatalf("write resume: %v", err)
+	}
+
+	var replayed []Message
+	timeout := time.After(expectTimeout)
+	for {
+		select {
+		case msg, ok := <-tc.frames:
+			if !ok {
+				t.Fatal("connection closed while waiting for resumed")
+			}
+			switch msg.Type {
+			case TypeMessage:
+				replayed = append(replayed, msg)
+			case TypeResumed:
+				var event ResumedEvent
+				if err := json.Unmarshal(msg.Data, &event); err != nil {
+					t.Fatalf("decode resumed: %v", err)
+				}
+				return event, replayed
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for resumed")
+		}
+	}
+}
+
+func TestResumeSkipsEventsOfBlockedSenders(t *testing.T) {
+	th := newTestHub(t)
+	roomID := uuid.New()
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	// bob заблокировал alice
+	th.hub.SetUserBlocks(bob, []uuid.UUID{alice}, nil)
+
+	th.hub.SendToRoomFrom(roomID, alice, encodeTestMessage(t, TypeMessage, roomID, alice, "from alice"))
+	th.hub.SendToRoomFrom(roomID, carol, encodeTestMessage(t, TypeMessage, roomID, carol, "from carol"))
+
+	bobConn := th.connect(t, bob, roomID)
+	resumed, replayed := bobConn.resume(t, roomID, 0)
+
+	if resumed.Replayed != 1 || len(replayed) != 1 {
+		t.Fatalf("expected 1 replayed event, got %d (%d frames)", resumed.Replayed, len(replayed))
+	}
+	if replayed[0].UserID != carol {
+		t.Fatalf("replayed event of %s, expected only carol's", replayed[0].UserID)
+	}
+	if !resumed.Complete {
+		t.Fatal("resume must be complete")
+	}
+}
+
+func TestResumeReplaysThreadRepliesToSubscribers(t *testing.T) {
+	th := newTestHub(t)
+	roomID, threadID := uuid.New(), uuid.New()
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	th.hub.SendToThread(roomID, threadID, alice, encodeTestMessage(t, TypeMessage, roomID, alice, "reply"))
+
+	bobConn := th.connect(t, bob, roomID)
+	if err := th.hub.SubscribeThread(<-th.clients, roomID, threadID); err != nil {
+		t.Fatalf("SubscribeThread: %v", err)
+	}
+	carolConn := th.connect(t, carol, roomID)
+
+	if resumed, _ := bobConn.resume(t, roomID, 0); resumed.Replayed != 1 {
+		t.Fatalf("thread subscriber must get the reply, replayed %d", resumed.Replayed)
+	}
+	if resumed, _ := carolConn.resume(t, roomID, 0); resumed.Replayed != 0 {
+		t.Fatalf("reply replayed to a client not subscribed to the thread")
+	}
+}
+
+// Номера выдаются и события ставятся в очереди под одной блокировкой,
+// поэтому при конкурентной отправке клиент видит seq строго по возрастанию
+func TestConcurrentSendsArriveInSeqOrder(t *testing.T) {
+	th := newTestHub(t)
+	roomID, alice := uuid.New(), uuid.New()
+	aliceConn := th.connect(t, alice, roomID)
+
+	const senders, perSender = 4, 10
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perSender; j++ {
+				th.hub.SendToRoom(roomID, encodeTestMessage(t, TypeMessage, roomID, alice, "hi"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	var last uint64
+	for i := 0; i < senders*perSender; i++ {
+		msg := aliceConn.expect(t, TypeMessage)
+		if msg.Seq <= last {
+			t.Fatalf("seq %d delivered after %d", msg.Seq, last)
+		}
+		last = msg.Seq
+	}
+}
+
+func TestMemoryEventLogPrunesIdleRooms(t *testing.T) {
+	log := newMemoryEventLog()
+	idle, active := uuid.New(), uuid.New()
+
+	oldSeq, _ := log.append(idle, uuid.Nil, uuid.Nil, []byte(`{}`))
+	log.rooms[idle].updated = time.Now().Add(-roomEventsTTL - time.Minute)
+	log.swept = time.Now().Add(-eventLogSweepInterval)
+
+	log.append(active, uuid.Nil, uuid.Nil, []byte(`{}`))
+	if _, ok := log.rooms[idle]; ok {
+		t.Fatal("idle room must be pruned")
+	}
+
+	// Старый номер клиента не должен скрывать события новой нумерации.
+	// Нумерация идет от времени создания буфера с точностью до микросекунды.
+	time.Sleep(time.Millisecond)
+	newSeq, _ := log.append(idle, uuid.Nil, uuid.Nil, []byte(`{}`))
+	if newSeq <= oldSeq {
+		t.Fatalf("seq restarted after prune: %d <= %d", newSeq, oldSeq)
+	}
+	if events, complete, _ := log.since(idle, oldSeq); len(events) != 1 || complete {
+		t.Fatalf("expected the new event and an incomplete replay, got %d events, complete=%v", len(events), complete)
+	}
+}
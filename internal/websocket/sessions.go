@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// Код закрытия соединений отозванной сессии: переподключаться с теми же токенами бессмысленно
+const CloseSessionRevoked = 4003
+
+const sessionRevokedReason = "session revoked"
+
+// CloseSession закрывает соединения сессии sessionID пользователя на всех инстансах
+func (h *Hub) CloseSession(userID uuid.UUID, sessionID string) {
+	h.closeSessions(userID, sessionID, false)
+}
+
+// CloseOtherSessions закрывает соединения пользователя на всех инстансах, кроме открытых в сессии keepID
+func (h *Hub) CloseOtherSessions(userID uuid.UUID, keepID string) {
+	h.closeSessions(userID, keepID, true)
+}
+
+func (h *Hub) closeSessions(userID uuid.UUID, sessionID string, others bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	h.closeSessionsLocal(userID, sessionID, others)
+
+	if h.cluster != nil {
+		h.cluster.publish(&clusterEnvelope{
+			Kind:      envelopeSession,
+			UserID:    &userID,
+			SessionID: sessionID,
+			Others:    others,
+		})
+	}
+}
+
+// closeSessionsLocal закрывает очереди подходящих соединений; из хаба их уберет ReadPump,
+// когда соединение закроется. Вызывающий должен держать h.mu.
+func (h *Hub) closeSessionsLocal(userID uuid.UUID, sessionID string, others bool) {
+	for _, client := range h.userClients[userID] {
+		if (client.SessionID == sessionID) == others {
+			continue
+		}
+
+		log.Printf("Client %s (User: %s) session revoked, disconnecting", client.ID, client.UserID)
+		client.close(CloseSessionRevoked, sessionRevokedReason)
+	}
+}
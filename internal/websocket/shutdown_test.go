@@ -0,0 +1,57 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Остановка сервера, выход клиента и отключение медленного клиента могут закрыть
+// одну и ту же очередь одновременно; закрыться она должна ровно один раз.
+// Запускать с -race.
+func TestConcurrentShutdownUnregisterAndKick(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		th := newTestHub(t)
+		roomID, userID := uuid.New(), uuid.New()
+
+		th.connect(t, userID, roomID)
+		client := <-th.clients
+
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		run := func(f func()) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				f()
+			}()
+		}
+
+		run(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), expectTimeout)
+			defer cancel()
+			if err := th.hub.Shutdown(ctx); err != nil {
+				t.Errorf("Shutdown: %v", err)
+			}
+		})
+		run(func() { th.hub.unregisterClient(client) })
+		run(func() { th.hub.Unregister(client) })
+		run(func() { client.disconnectSlow() })
+		run(func() { th.hub.RemoveUserFromRoom(userID, roomID) })
+		run(func() { client.enqueue([]byte(`{"type":"ping"}`)) })
+
+		close(start)
+		wg.Wait()
+
+		// Shutdown ждет только клиентов, которых не успел убрать unregister
+		select {
+		case <-client.done:
+		case <-time.After(expectTimeout):
+			t.Fatalf("iteration %d: write pump did not finish", i)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// SubscribeThread подписывает клиента на ответы открытого треда комнаты
+func (h *Hub) SubscribeThread(client *Client, roomID, threadID uuid.UUID) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !client.IsInRoom(roomID) {
+		return ErrUserNotInRoom
+	}
+
+	if _, ok := h.threads[threadID]; !ok {
+		h.threads[threadID] = make(map[uuid.UUID]*Client)
+	}
+	h.threads[threadID][client.ID] = client
+
+	client.mu.Lock()
+	client.Threads[threadID] = roomID
+	client.mu.Unlock()
+
+	return nil
+}
+
+// UnsubscribeThread отписывает клиента от треда
+func (h *Hub) UnsubscribeThread(client *Client, threadID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.unsubscribeThreadUnsafe(client, threadID)
+}
+
+func (h *Hub) unsubscribeThreadUnsafe(client *Client, threadID uuid.UUID) {
+	if subs, ok := h.threads[threadID]; ok {
+		delete(subs, client.ID)
+		if len(subs) == 0 {
+			delete(h.threads, threadID)
+		}
+	}
+
+	client.mu.Lock()
+	delete(client.Threads, threadID)
+	client.mu.Unlock()
+}
+
+// unsubscribeRoomThreadsUnsafe отписывает клиента от всех тредов комнаты
+func (h *Hub) unsubscribeRoomThreadsUnsafe(client *Client, roomID uuid.UUID) {
+	client.mu.RLock()
+	threadIDs := make([]uuid.UUID, 0)
+	for threadID, threadRoomID := range client.Threads {
+		if threadRoomID == roomID {
+			threadIDs = append(threadIDs, threadID)
+		}
+	}
+	client.mu.RUnlock()
+
+	for _, threadID := range threadIDs {
+		h.unsubscribeThreadUnsafe(client, threadID)
+	}
+}
+
+// SendToThread отправляет ответ подписчикам треда на всех инстансах. Ответ нумеруется
+// в журнале комнаты и досылается при resume тем, кто подписан на тред.
+// Подписчики, заблокировавшие отправителя, его не получают.
+func (h *Hub) SendToThread(roomID, threadID, senderID uuid.UUID, message []byte) {
+	lock := h.roomSeqLock(roomID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	message = h.sequence(roomID, senderID, threadID, message)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	h.sendToThreadLocal(roomID, threadID, senderID, message)
+
+	if h.cluster != nil {
+		h.cluster.publish(&clusterEnvelope{
+			Kind:     envelopeThread,
+			RoomID:   &roomID,
+			ThreadID: &threadID,
+			SenderID: &senderID,
+			Payload:  message,
+		})
+	}
+}
+
+func (h *Hub) sendToThreadLocal(roomID, threadID, senderID uuid.UUID, message []byte) {
+	for _, client := range h.threads[threadID] {
+		if senderID != uuid.Nil && h.blocked[client.UserID][senderID] {
+			continue
+		}
+		if err := client.enqueueRoom(roomID, message); err == ErrClientQueueFull {
+			log.Printf("Client %s send channel full", client.ID)
+		}
+	}
+}
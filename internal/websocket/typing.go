@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Через сколько сервер сам снимает индикатор, если клиент не прислал typing_stop
+	typingTimeout = 5 * time.Second
+
+	// Минимальный интервал между рассылками typing_start от одного клиента в комнату
+	typingThrottle = 2 * time.Second
+)
+
+// typingState состояние набора текста клиентом в одной комнате
+type typingState struct {
+	lastSent time.Time
+	timer    *time.Timer
+
+	// Поколение таймера, чтобы устаревший таймер не снял обновленный индикатор
+	gen uint64
+}
+
+// StartTyping отмечает, что клиент набирает текст в комнате.
+// Повторные вызовы продлевают индикатор, но рассылаются не чаще typingThrottle.
+func (h *Hub) StartTyping(client *Client, roomID uuid.UUID) {
+	if !client.IsInRoom(roomID) {
+		return
+	}
+
+	now := time.Now()
+
+	client.mu.Lock()
+	if client.typing == nil {
+		client.typing = make(map[uuid.UUID]*typingState)
+	}
+	state, ok := client.typing[roomID]
+	if !ok {
+		state = &typingState{}
+		client.typing[roomID] = state
+	}
+
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+	state.gen++
+	gen := state.gen
+	state.timer = time.AfterFunc(typingTimeout, func() {
+		h.expireTyping(client, roomID, gen)
+	})
+
+	notify := now.Sub(state.lastSent) >= typingThrottle
+	if notify {
+		state.lastSent = now
+	}
+	client.mu.Unlock()
+
+	if notify {
+		h.broadcastTyping(client, roomID, TypeTypingStart)
+	}
+}
+
+// StopTyping снимает индикатор набора текста клиента в комнате
+func (h *Hub) StopTyping(client *Client, roomID uuid.UUID) {
+	if client.clearTyping(roomID) {
+		h.broadcastTyping(client, roomID, TypeTypingStop)
+	}
+}
+
+// expireTyping снимает индикатор по таймауту, если он не был продлен
+func (h *Hub) expireTyping(client *Client, roomID uuid.UUID, gen uint64) {
+	client.mu.Lock()
+	state, ok := client.typing[roomID]
+	if !ok || state.gen != gen {
+		client.mu.Unlock()
+		return
+	}
+	delete(client.typing, roomID)
+	client.mu.Unlock()
+
+	h.broadcastTyping(client, roomID, TypeTypingStop)
+}
+
+func (h *Hub) broadcastTyping(client *Client, roomID uuid.UUID, msgType MessageType) {
+	msg := Message{
+		Type:      msgType,
+		RoomID:    &roomID,
+		UserID:    client.UserID,
+		Timestamp: time.Now(),
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	// Клиент мог покинуть комнату, пока срабатывал таймер
+	if _, ok := h.rooms[roomID][client.ID]; !ok {
+		return
+	}
+
+	h.broadcastToRoomExcept(roomID, data, client.ID)
+}
+
+// clearTyping сбрасывает состояние набора в комнате и сообщает, было ли оно активно
+func (c *Client) clearTyping(roomID uuid.UUID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, ok := c.typing[roomID]
+	if !ok {
+		return false
+	}
+
+	state.timer.Stop()
+	delete(c.typing, roomID)
+	return true
+}
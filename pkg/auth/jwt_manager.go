@@ -15,24 +15,38 @@ type JWTManager struct {
 	tokenDuration time.Duration
 }
 
+// Claims содержит идентификатор сессии, к которой привязан токен
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+}
+
 func NewJWTManager(secret string, duration time.Duration) *JWTManager {
 	return &JWTManager{secretKey: secret, tokenDuration: duration}
 }
 
-// Generate создаёт JWT для userID
-func (m *JWTManager) Generate(userID string) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Subject:   userID,
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.tokenDuration)),
+// Generate создаёт JWT для userID в рамках сессии sessionID
+func (m *JWTManager) Generate(userID, sessionID string) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.tokenDuration)),
+		},
+		SessionID: sessionID,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(m.secretKey))
 }
 
+// TokenDuration возвращает время жизни access токена
+func (m *JWTManager) TokenDuration() time.Duration {
+	return m.tokenDuration
+}
+
 // Verify парсит и проверяет JWT
-func (m *JWTManager) Verify(accessToken string) (*jwt.RegisteredClaims, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
+func (m *JWTManager) Verify(accessToken string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(accessToken, &Claims{}, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
@@ -41,7 +55,7 @@ func (m *JWTManager) Verify(accessToken string) (*jwt.RegisteredClaims, error) {
 	if err != nil {
 		return nil, err
 	}
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
 		return nil, errors.New("invalid token")
 	}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	sessionKeyPrefix      = "session:"
+	userSessionsKeyPrefix = "user_sessions:"
+	refreshKeyPrefix      = "refresh:"
+	refreshUsedKeyPrefix  = "refresh_used:"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// Продлевает сессию новым refresh токеном, только если она не отозвана и её текущий
+// токен — обмениваемый. Иначе отзыв между GETDEL и обновлением воскресил бы сессию.
+// KEYS: сессия, метка использованного токена, новый токен.
+// ARGV: хеш старого токена, хеш нового, sessionID, last_used_at, TTL (ms).
+var rotateScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'refresh_hash') ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'last_used_at', ARGV[4], 'refresh_hash', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+redis.call('SET', KEYS[2], ARGV[3], 'PX', ARGV[5])
+redis.call('SET', KEYS[3], ARGV[3], 'PX', ARGV[5])
+return 1
+`)
+
+// Session активная сессия (устройство) пользователя
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// SessionManager хранит сессии и ротируемые refresh токены в Redis.
+// Каждая сессия — семейство refresh токенов: при ротации старый токен помечается
+// использованным, и его повторное предъявление отзывает всю сессию.
+type SessionManager struct {
+	redis      *redis.Client
+	refreshTTL time.Duration
+}
+
+func NewSessionManager(rdb *redis.Client, refreshTTL time.Duration) *SessionManager {
+	return &SessionManager{redis: rdb, refreshTTL: refreshTTL}
+}
+
+// Create открывает новую сессию и возвращает её вместе с первым refresh токеном
+func (m *SessionManager) Create(ctx context.Context, userID, userAgent, ip string) (*Session, string, error) {
+	now := time.Now()
+	session := &Session{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	pipe := m.redis.TxPipeline()
+	pipe.HSet(ctx, sessionKeyPrefix+session.ID, map[string]interface{}{
+		"user_id":      session.UserID,
+		"user_agent":   session.UserAgent,
+		"ip":           session.IP,
+		"created_at":   session.CreatedAt.Unix(),
+		"last_used_at": session.LastUsedAt.Unix(),
+		"refresh_hash": refreshHash,
+	})
+	pipe.Expire(ctx, sessionKeyPrefix+session.ID, m.refreshTTL)
+	pipe.SAdd(ctx, userSessionsKeyPrefix+userID, session.ID)
+	pipe.Set(ctx, refreshKeyPrefix+refreshHash, session.ID, m.refreshTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, "", err
+	}
+
+	return session, refreshToken, nil
+}
+
+// Rotate обменивает refresh токен на новый. Повторное использование уже
+// обменянного токена отзывает сессию целиком.
+func (m *SessionManager) Rotate(ctx context.Context, refreshToken string) (*Session, string, error) {
+	oldHash := hashToken(refreshToken)
+
+	// GETDEL атомарен: из двух параллельных запросов с одним токеном выиграет один
+	sessionID, err := m.redis.GetDel(ctx, refreshKeyPrefix+oldHash).Result()
+	if err == redis.Nil {
+		usedBy, err := m.redis.Get(ctx, refreshUsedKeyPrefix+oldHash).Result()
+		if err == nil {
+			m.Revoke(ctx, usedBy)
+			return nil, "", ErrRefreshTokenReused
+		}
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	session, err := m.Get(ctx, sessionID)
+	if err != nil {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	newToken, newHash, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	session.LastUsedAt = time.Now()
+
+	rotated, err := rotateScript.Run(ctx, m.redis,
+		[]string{sessionKeyPrefix + sessionID, refreshUsedKeyPrefix + oldHash, refreshKeyPrefix + newHash},
+		oldHash, newHash, sessionID, session.LastUsedAt.Unix(), m.refreshTTL.Milliseconds(),
+	).Int()
+	if err != nil {
+		return nil, "", err
+	}
+	if rotated == 0 {
+		// Сессию отозвали, пока токен обменивался
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	return session, newToken, nil
+}
+
+// Get возвращает сессию по ID
+func (m *SessionManager) Get(ctx context.Context, sessionID string) (*Session, error) {
+	fields, err := m.redis.HGetAll(ctx, sessionKeyPrefix+sessionID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	return sessionFromFields(sessionID, fields), nil
+}
+
+// IsActive проверяет, что сессия не отозвана и не истекла
+func (m *SessionManager) IsActive(ctx context.Context, sessionID string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+
+	n, err := m.redis.Exists(ctx, sessionKeyPrefix+sessionID).Result()
+	return n > 0, err
+}
+
+// List возвращает активные сессии пользователя, самые свежие первыми
+func (m *SessionManager) List(ctx context.Context, userID string) ([]*Session, error) {
+	ids, err := m.redis.SMembers(ctx, userSessionsKeyPrefix+userID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := m.Get(ctx, id)
+		if err == ErrSessionNotFound {
+			// Сессия истекла, убираем ссылку на неё
+			m.redis.SRem(ctx, userSessionsKeyPrefix+userID, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// Revoke отзывает сессию вместе с её текущим refresh токеном
+func (m *SessionManager) Revoke(ctx context.Context, sessionID string) error {
+	fields, err := m.redis.HGetAll(ctx, sessionKeyPrefix+sessionID).Result()
+	if err != nil {
+		return err
+	}
+
+	if len(fields) == 0 {
+		return ErrSessionNotFound
+	}
+
+	pipe := m.redis.TxPipeline()
+	pipe.Del(ctx, sessionKeyPrefix+sessionID)
+	pipe.SRem(ctx, userSessionsKeyPrefix+fields["user_id"], sessionID)
+	if hash := fields["refresh_hash"]; hash != "" {
+		pipe.Del(ctx, refreshKeyPrefix+hash)
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RevokeOthers отзывает все сессии пользователя, кроме keepID
+func (m *SessionManager) RevokeOthers(ctx context.Context, userID, keepID string) error {
+	ids, err := m.redis.SMembers(ctx, userSessionsKeyPrefix+userID).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id == keepID {
+			continue
+		}
+		if err := m.Revoke(ctx, id); err != nil && err != ErrSessionNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func sessionFromFields(id string, fields map[string]string) *Session {
+	session := &Session{
+		ID:        id,
+		UserID:    fields["user_id"],
+		UserAgent: fields["user_agent"],
+		IP:        fields["ip"],
+	}
+
+	if ts, err := parseUnix(fields["created_at"]); err == nil {
+		session.CreatedAt = ts
+	}
+	if ts, err := parseUnix(fields["last_used_at"]); err == nil {
+		session.LastUsedAt = ts
+	}
+
+	return session
+}
+
+func parseUnix(v string) (time.Time, error) {
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// newRefreshToken генерирует случайный токен; в Redis хранится только его хеш
+func newRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const keyPrefix = "ratelimit:"
+
+// Limit бюджет token bucket: Rate токенов за Period, не больше Burst подряд
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// PerMinute бюджет из n запросов в минуту с запасом burst
+func PerMinute(n, burst int) Limit {
+	return Limit{Rate: n, Period: time.Minute, Burst: burst}
+}
+
+// PerHour бюджет из n запросов в час с запасом burst
+func PerHour(n, burst int) Limit {
+	return Limit{Rate: n, Period: time.Hour, Burst: burst}
+}
+
+// perMillisecond скорость пополнения в токенах за миллисекунду
+func (l Limit) perMillisecond() float64 {
+	return float64(l.Rate) / float64(l.Period.Milliseconds())
+}
+
+// Budget бюджет, закрепленный за ключом (пользователь, IP, комната)
+type Budget struct {
+	Key   string
+	Limit Limit
+}
+
+// Result результат проверки бюджета
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Через сколько появится следующий токен, если запрос отклонен
+	RetryAfter time.Duration
+
+	// Через сколько бюджет полностью восстановится
+	ResetAfter time.Duration
+}
+
+// Пополняет bucket каждого бюджета по прошедшему времени и списывает по токену
+// из всех сразу, только если токен есть в каждом. Иначе ничего не списывает.
+// ARGV: now, затем rate и burst по порядку KEYS. Возвращает {rejected, tokens...}:
+// rejected — номер первого исчерпанного бюджета с 1, 0 если запрос разрешен;
+// tokens строками, чтобы Redis не округлил дробь.
+var tokenBucketScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local tokens = {}
+local rejected = 0
+
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[i * 2])
+	local burst = tonumber(ARGV[i * 2 + 1])
+	local state = redis.call('HMGET', key, 'tokens', 'ts')
+	local t = tonumber(state[1]) or burst
+	local ts = tonumber(state[2]) or now
+
+	tokens[i] = math.min(burst, t + math.max(0, now - ts) * rate)
+	if tokens[i] < 1 and rejected == 0 then
+		rejected = i
+	end
+end
+
+local result = {rejected}
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[i * 2])
+	local burst = tonumber(ARGV[i * 2 + 1])
+	if rejected == 0 then
+		tokens[i] = tokens[i] - 1
+	end
+
+	redis.call('HSET', key, 'tokens', tostring(tokens[i]), 'ts', now)
+	redis.call('PEXPIRE', key, math.ceil(burst / rate))
+	result[i + 1] = tostring(tokens[i])
+end
+return result
+`)
+
+// Limiter token bucket в Redis, общий для всех инстансов
+type Limiter struct {
+	redis *redis.Client
+}
+
+func NewLimiter(rdb *redis.Client) *Limiter {
+	return &Limiter{redis: rdb}
+}
+
+// Allow списывает токен из бюджета key
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return l.AllowAll(ctx, []Budget{{Key: key, Limit: limit}})
+}
+
+// AllowAll атомарно списывает по токену из всех бюджетов. Если исчерпан хотя бы один,
+// не списывает ни из одного и возвращает результат первого исчерпанного.
+// Иначе возвращает результат самого исчерпанного бюджета.
+func (l *Limiter) AllowAll(ctx context.Context, budgets []Budget) (Result, error) {
+	if len(budgets) == 0 {
+		return Result{Allowed: true}, nil
+	}
+
+	keys := make([]string, len(budgets))
+	args := []interface{}{time.Now().UnixMilli()}
+	for i, budget := range budgets {
+		keys[i] = keyPrefix + budget.Key
+		args = append(args, budget.Limit.perMillisecond(), budget.Limit.Burst)
+	}
+
+	values, err := tokenBucketScript.Run(ctx, l.redis, keys, args...).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != len(budgets)+1 {
+		return Result{}, fmt.Errorf("unexpected rate limit reply of %d values", len(values))
+	}
+
+	rejected, _ := values[0].(int64)
+
+	var tightest Result
+	for i, budget := range budgets {
+		raw, _ := values[i+1].(string)
+		tokens, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return Result{}, err
+		}
+
+		result := newResult(budget.Limit, tokens, rejected == 0)
+		if int(rejected) == i+1 {
+			return result, nil
+		}
+		if i == 0 || result.Remaining < tightest.Remaining {
+			tightest = result
+		}
+	}
+	return tightest, nil
+}
+
+// newResult считает заголовки бюджета по остатку токенов после проверки
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.perMillisecond()
+
+	result := Result{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(limit.Burst)-tokens)/rate) * time.Millisecond,
+	}
+	if !allowed && tokens < 1 {
+		result.RetryAfter = time.Duration((1-tokens)/rate) * time.Millisecond
+	}
+	return result
+}
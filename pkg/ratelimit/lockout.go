@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	failuresKeyPrefix = "lockout_failures:"
+	lockKeyPrefix     = "lockout:"
+)
+
+// Считает неудачу в окне и ставит блокировку, когда их набралось достаточно.
+// Возвращает оставшееся время блокировки в мс, 0 если ключ не заблокирован.
+var lockoutFailScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if n >= tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[2], 1, 'PX', ARGV[3])
+	redis.call('DEL', KEYS[1])
+	return tonumber(ARGV[3])
+end
+return 0
+`)
+
+// Lockout временно блокирует ключ (например, email при входе) после серии неудач
+type Lockout struct {
+	redis       *redis.Client
+	maxFailures int
+	window      time.Duration
+	duration    time.Duration
+}
+
+// NewLockout блокирует ключ на duration после maxFailures неудач за window
+func NewLockout(rdb *redis.Client, maxFailures int, window, duration time.Duration) *Lockout {
+	return &Lockout{
+		redis:       rdb,
+		maxFailures: maxFailures,
+		window:      window,
+		duration:    duration,
+	}
+}
+
+// Locked возвращает оставшееся время блокировки, 0 если ключ свободен
+func (l *Lockout) Locked(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := l.redis.PTTL(ctx, lockKeyPrefix+key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Fail отмечает неудачную попытку и возвращает время блокировки, если она наступила
+func (l *Lockout) Fail(ctx context.Context, key string) (time.Duration, error) {
+	keys := []string{failuresKeyPrefix + key, lockKeyPrefix + key}
+	ms, err := lockoutFailScript.Run(ctx, l.redis, keys, l.maxFailures, l.window.Milliseconds(), l.duration.Milliseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Reset сбрасывает счетчик неудач после успешной попытки
+func (l *Lockout) Reset(ctx context.Context, key string) error {
+	return l.redis.Del(ctx, failuresKeyPrefix+key).Err()
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	ErrInvalidKey     = errors.New("invalid storage key")
+	ErrObjectNotFound = errors.New("object not found")
+)
+
+// Storage хранилище бинарных объектов (вложений, превью)
+type Storage interface {
+	// Put сохраняет объект под ключом key и возвращает публичный URL
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+
+	// Open открывает объект для чтения
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+
+	// Delete удаляет объект, отсутствие объекта не считается ошибкой
+	Delete(ctx context.Context, key string) error
+
+	// KeyFromURL восстанавливает ключ объекта по URL, выданному Put
+	KeyFromURL(url string) (string, bool)
+
+	// URL возвращает URL объекта, который выдал бы Put
+	URL(key string) string
+}